@@ -7,13 +7,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/Templum/rabbitmq-connector/pkg/cli"
 	"github.com/Templum/rabbitmq-connector/pkg/config"
 	"github.com/Templum/rabbitmq-connector/pkg/connector"
 	"github.com/Templum/rabbitmq-connector/pkg/openfaas"
@@ -26,6 +29,17 @@ import (
 )
 
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || args[0] == "-h" || args[0] == "--help") {
+		os.Exit(cli.Run(afero.NewOsFs(), args, os.Stdout, os.Stderr))
+	}
+
+	// Flags and the config file are exposed as envs, which are read by the config afterwards
+	if _, err := config.Load(afero.NewOsFs(), args, os.Stderr); err != nil {
+		log.Printf("During Config loading %s occurred.", err)
+		os.Exit(cli.ExitUsage)
+	}
+
 	commit, tag := version.GetReleaseInfo()
 	log.Printf("OpenFaaS RabbitMQ Connector [Version: %s Commit: %s]", tag, commit)
 
@@ -46,33 +60,121 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	httpClient := types.MakeHTTPClient(conf.InsecureSkipVerify, conf.MaxClientsPerHost, 60*time.Second)
+	// Timeouts are enforced per request via deadlines, as invocations may take longer than REQ_TIMEOUT
+	httpClient := types.MakeHTTPClient(conf.GatewayTLSConfig, conf.MaxClientsPerHost, 0)
 	// Setup OpenFaaS Controller which is used for querying and more
-	ofSDK := openfaas.NewController(conf, openfaas.NewClient(httpClient, conf.BasicAuth, conf.GatewayURL), openfaas.NewTopicFunctionCache())
+	authProvider := openfaas.NewAuthProvider(afero.NewOsFs(), conf)
+	if credentials, ok := authProvider.(*openfaas.BasicAuthFile); ok {
+		go credentials.Watch(ctx, conf.GatewayAuthWatchInterval)
+	}
+
+	ofSDK := openfaas.NewController(conf, openfaas.NewClient(httpClient, authProvider, conf.GatewayURL).WithTimeout(conf.RequestTimeout), openfaas.NewTopicFunctionCache())
+	conOpts := conf.ConnectionOptions()
+	var locator *rabbitmq.ManagementLocator
+	if len(conf.Management.URL) > 0 {
+		var err error
+		locator, err = rabbitmq.NewManagementLocator(conf.Management.URL, conf.RabbitConnectionURLs[0], conf.Management.TLSConfig)
+		if err != nil {
+			log.Fatalf("During Config validation %s occurred.", err)
+		}
+		if len(conf.Management.User) > 0 {
+			locator.WithCredentials(conf.Management.User, conf.Management.Password)
+		}
+		conOpts.Locator = locator
+		log.Printf("Will prefer the node hosting the queue leaders reported by %s", conf.Management.URL)
+	}
+
+	manager := rabbitmq.NewConnectionManager(rabbitmq.NewBroker(), conOpts)
+	c := connector.New(manager, rabbitmq.NewFactory(), ofSDK, conf)
+	if locator != nil {
+		// Asked on every (re)connect, so reloaded topologies and dynamic bindings are located as well
+		locator.WithQueues(c.Queues)
+	}
+
+	if conf.DynamicBindings {
+		log.Printf("Dynamic bindings are enabled, queues will be derived from function annotations")
+		ofSDK.WithBindingListener(c)
+	}
+
 	go ofSDK.Start(ctx)
 	log.Printf("Started Cache Task which populates the topic map")
 
-	c := connector.New(rabbitmq.NewConnectionManager(rabbitmq.NewBroker(), conf.TLSConfig), rabbitmq.NewFactory(), ofSDK, conf)
+	if len(conf.HealthAddr) > 0 {
+		health := connector.NewHealthServer(conf.HealthAddr, c)
+		go func() {
+			if err := health.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Received %s from health server", err)
+			}
+		}()
+		defer health.Close()
+		log.Printf("Serving health checks on %s", conf.HealthAddr)
+	}
+
 	err := c.Run()
 
 	if err != nil {
 		log.Fatalf("Received %s during Connector starting", err)
 	}
 
+	watcher := config.NewTopologyWatcher(afero.NewOsFs(), conf)
+	go func() {
+		for topology := range watcher.Watch(ctx) {
+			if err := c.Reload(topology); err != nil {
+				log.Printf("Received %s while reloading topology", err)
+			}
+		}
+	}()
+
+	var certWatcher *config.CertificateWatcher
+	if conf.TLSConfig != nil {
+		certWatcher = config.NewCertificateWatcher(afero.NewOsFs(), conf)
+		rotation := connector.NewCertificateRotation(c, manager, conf.TLSConfig, conf.TLSReconnectBeforeExpiry)
+		defer rotation.Stop()
+
+		go func() {
+			for tlsConfig := range certWatcher.Watch(ctx) {
+				rotation.Apply(tlsConfig)
+			}
+		}()
+	}
+
+	credentialWatcher := config.NewCredentialWatcher(afero.NewOsFs(), conf)
+	go func() {
+		for rotated := range credentialWatcher.Watch(ctx) {
+			if locator != nil {
+				if err := locator.UpdateCredentials(rotated.URLs[0]); err != nil {
+					log.Printf("Received %s while updating credentials of the management api", err)
+				}
+			}
+
+			if err := c.UpdateConnectionURLs(rotated.URLs, rotated.SanitizedURL); err != nil {
+				log.Printf("Received %s while reconnecting with the rotated credentials", err)
+			}
+		}
+	}()
+
 	signalChannel := make(chan os.Signal, 2)
-	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	log.Printf(" [*] Waiting for messages. To exit press CTRL+C")
 
-	sig := <-signalChannel
-	switch sig {
-	case os.Interrupt:
-		log.Printf("Received SIGINT preparing for shutdown")
+	for sig := range signalChannel {
+		switch sig {
+		case syscall.SIGHUP:
+			log.Printf("Received SIGHUP reloading topology")
+			watcher.Trigger()
+			credentialWatcher.Trigger()
+			if certWatcher != nil {
+				certWatcher.Trigger()
+			}
+			continue
+		case os.Interrupt:
+			log.Printf("Received SIGINT preparing for shutdown")
+		case syscall.SIGTERM:
+			log.Printf("Received SIGTERM shutting down")
+		}
 
 		c.Shutdown()
 		cancel()
-	case syscall.SIGTERM:
-		log.Printf("Received SIGTERM shutting down")
-		c.Shutdown()
-		cancel()
+		return
 	}
 }
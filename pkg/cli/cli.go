@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+)
+
+// Exit codes returned by the commands
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+const usage = `Usage: rmq-connector [command]
+
+Without a command the connector is started.
+
+Commands:
+  validate    Validates topology files without connecting to RabbitMQ
+  plan        Shows what applying the topology would change on RabbitMQ
+  teardown    Deletes the queues and exchanges managed by the connector
+  export      Renders the effective topology as RabbitMQ definitions or yaml
+  schema      Prints the JSON Schema of the topology, which is only enforced with TOPOLOGY_STRICT
+  config      Prints the effective configuration via config print
+
+The connector is configured via envs, a yaml config file named by --config or CONFIG_FILE and
+flags named after the envs, e.g. --rmq-host for RMQ_HOST. Flags take precedence over envs,
+which take precedence over the config file.
+`
+
+// Run executes the command named by the first argument and returns the exit code of it
+func Run(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "plan", "teardown", "export":
+		// Commands relying on the connector config pick up its config file as well
+		if _, err := config.Load(fs, nil, stderr); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Invalid config: %s\n", err)
+			return ExitFailure
+		}
+	}
+
+	switch args[0] {
+	case "validate":
+		return Validate(fs, args[1:], stdout, stderr)
+	case "plan":
+		return Plan(fs, args[1:], stdout, stderr)
+	case "teardown":
+		return Teardown(fs, args[1:], stdout, stderr)
+	case "export":
+		return Export(fs, args[1:], stdout, stderr)
+	case "config":
+		return Config(fs, args[1:], stdout, stderr)
+	case "schema":
+		_, _ = stdout.Write(types.TopologySchema)
+		// Goes to stderr, so the schema can still be piped into other tools
+		_, _ = fmt.Fprintln(stderr, schemaNote)
+		return ExitOK
+	case "help", "-h", "--help":
+		_, _ = fmt.Fprint(stdout, usage)
+		return ExitOK
+	default:
+		_, _ = fmt.Fprintf(stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return ExitUsage
+	}
+}
+
+const schemaNote = `The connector only rejects topologies that do not match this schema with TOPOLOGY_STRICT enabled, otherwise
+it logs the problems and falls back to defaults. The validate command always enforces it.`
+
+// connect establishes a connection to RabbitMQ, in contrast to the connector it does not retry infinitely
+func connect(conf *config.Controller) (rabbitmq.Manager, error) {
+	opts := conf.ConnectionOptions()
+	if opts.Policy.MaxAttempts == 0 {
+		opts.Policy.MaxAttempts = 3
+	}
+
+	manager := rabbitmq.NewConnectionManager(rabbitmq.NewBroker(), opts)
+	if _, err := manager.Connect(conf.RabbitConnectionURLs); err != nil {
+		return nil, err
+	}
+
+	return manager, nil
+}
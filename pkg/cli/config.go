@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/spf13/afero"
+)
+
+// Config prints the effective configuration resolved from the config file, envs and flags, with secrets redacted and
+// the origin of every value as comment. It fails if the configuration is invalid, reporting every problem found.
+func Config(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	usage := func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: rmq-connector config print [flags]")
+		_, _ = fmt.Fprintln(stderr, "Prints the effective, redacted configuration. Accepts the same flags as the connector.")
+	}
+
+	if len(args) == 0 || args[0] != "print" {
+		usage()
+		return ExitUsage
+	}
+
+	origins, err := config.Load(fs, args[1:], stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		usage()
+		return ExitUsage
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid config: %s\n", err)
+		return ExitUsage
+	}
+
+	if err := config.PrintSettings(stdout, origins); err != nil {
+		_, _ = fmt.Fprintf(stderr, "Failed to print config: %s\n", err)
+		return ExitFailure
+	}
+
+	if _, err := config.NewConfig(fs); err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid config: %s\n", err)
+		return ExitFailure
+	}
+
+	return ExitOK
+}
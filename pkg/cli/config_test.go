@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "topology.yaml", []byte(`- name: AEx
+  topics: [Foo]`), 0644)
+	_ = afero.WriteFile(fs, "connector.yaml", []byte("path-to-topology: topology.yaml\nrmq-pass: s3cr3t\n"), 0644)
+
+	t.Run("Should print effective config with origins", func(t *testing.T) {
+		defer os.Unsetenv("PATH_TO_TOPOLOGY")
+		defer os.Unsetenv("RMQ_PASS")
+		defer os.Unsetenv("RMQ_HOST")
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"config", "print", "--config", "connector.yaml", "--rmq-host", "rabbit"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code, stderr.String())
+		assert.Contains(t, stdout.String(), "path-to-topology: topology.yaml # file\n")
+		assert.Contains(t, stdout.String(), "rmq-host: rabbit # flag\n")
+		assert.Contains(t, stdout.String(), "rmq-pass: <redacted> # file\n")
+		assert.NotContains(t, stdout.String(), "s3cr3t", "Should not leak secrets")
+	})
+
+	t.Run("Should report all invalid values", func(t *testing.T) {
+		defer os.Unsetenv("PATH_TO_TOPOLOGY")
+		defer os.Unsetenv("RMQ_PORT")
+		defer os.Unsetenv("RMQ_HEARTBEAT")
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"config", "print", "--path-to-topology", "topology.yaml", "--rmq-port", "amqp", "--rmq-heartbeat", "often"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), "Provided RMQ_PORT amqp is not a valid port")
+		assert.Contains(t, stderr.String(), "Provided RMQ_HEARTBEAT often is not a valid Duration")
+	})
+
+	t.Run("Should reject unknown sub commands and flags", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		assert.Equal(t, ExitUsage, Run(fs, []string{"config"}, stdout, stderr))
+		assert.Equal(t, ExitUsage, Run(fs, []string{"config", "show"}, stdout, stderr))
+		assert.Equal(t, ExitUsage, Run(fs, []string{"config", "print", "--rmq-topics", "billing"}, stdout, stderr))
+		assert.Contains(t, stderr.String(), "Usage: rmq-connector config print [flags]")
+	})
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+// Export renders the effective topology, after applying defaults and generating the queue names, either as
+// RabbitMQ definitions or as the topology yaml of the connector.
+func Export(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "definitions", "output format, either definitions or yaml")
+	vhost := flags.String("vhost", defaultVHost(), "vhost used for the definitions")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: rmq-connector export [flags] [topology file]")
+		_, _ = fmt.Fprintln(stderr, "Renders the effective topology, defaults to PATH_TO_TOPOLOGY.")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	path := flags.Arg(0)
+	if len(path) == 0 {
+		path = os.Getenv("PATH_TO_TOPOLOGY")
+	}
+
+	topology, err := config.ReadTopology(fs, path, false)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid topology: %s\n", err)
+		return ExitFailure
+	}
+
+	var out []byte
+	switch *format {
+	case "definitions":
+		out, err = json.MarshalIndent(rabbitmq.ExportDefinitions(topology, *vhost), "", "  ")
+		out = append(out, '\n')
+	case "yaml":
+		out, err = yaml.Marshal(rabbitmq.EffectiveTopology(topology))
+	default:
+		_, _ = fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		flags.Usage()
+		return ExitUsage
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Failed to render topology: %s\n", err)
+		return ExitFailure
+	}
+
+	_, _ = stdout.Write(out)
+	return ExitOK
+}
+
+func defaultVHost() string {
+	if vhost := os.Getenv("RMQ_VHOST"); len(vhost) > 0 {
+		return vhost
+	}
+	return "/"
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "topology.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  declare: true
+  type: Topic`), 0644)
+
+	t.Run("Should render definitions by default", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"export", "--vhost", "/staging", "topology.yaml"}, stdout, stderr)
+
+		var defs types.Definitions
+		assert.Equal(t, ExitOK, code)
+		assert.NoError(t, json.Unmarshal(stdout.Bytes(), &defs))
+		assert.Equal(t, "topic", defs.Exchanges[0].Type)
+		assert.Equal(t, "/staging", defs.Exchanges[0].VHost)
+		assert.Equal(t, "OpenFaaS_AEx_Foo", defs.Queues[0].Name)
+		assert.Equal(t, "OpenFaaS_AEx_Foo", defs.Bindings[0].Destination)
+	})
+
+	t.Run("Should render effective topology as yaml", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"export", "--format", "yaml", "topology.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code)
+		assert.Equal(t, "- name: AEx\n  topics:\n  - Foo\n  declare: true\n  type: topic\n", stdout.String())
+	})
+
+	t.Run("Should reject unknown format", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"export", "--format", "xml", "topology.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitUsage, code)
+		assert.Contains(t, stderr.String(), "Unknown format \"xml\"")
+	})
+
+	t.Run("Should fail for missing topology", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"export", "missing.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), "Invalid topology")
+	})
+}
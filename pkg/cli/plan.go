@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/spf13/afero"
+)
+
+// Plan connects to RabbitMQ using the connector config and prints what applying the topology would create,
+// what already matches and what conflicts, without modifying anything. It fails if any conflict was found.
+func Plan(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: rmq-connector plan [topology file]")
+		_, _ = fmt.Fprintln(stderr, "Compares the topology, defaults to PATH_TO_TOPOLOGY, against the configured RabbitMQ.")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	conf, err := config.NewConfig(fs)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid config: %s\n", err)
+		return ExitFailure
+	}
+
+	topology := conf.Topology
+	if flags.NArg() > 0 {
+		topology, err = config.ReadTopology(fs, flags.Arg(0), conf.StrictTopology)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Invalid topology: %s\n", err)
+			return ExitFailure
+		}
+	}
+
+	manager, err := connect(conf)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Could not connect to %s: %s\n", conf.RabbitSanitizedURL, err)
+		return ExitFailure
+	}
+	defer manager.Disconnect()
+
+	entries, err := rabbitmq.Plan(manager, topology)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Failed to inspect %s: %s\n", conf.RabbitSanitizedURL, err)
+		return ExitFailure
+	}
+
+	if printPlan(entries, stdout) > 0 {
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+// printPlan prints the entries in a diff like format and returns the number of conflicts
+func printPlan(entries []rabbitmq.PlanEntry, out io.Writer) int {
+	symbols := map[rabbitmq.PlanAction]string{
+		rabbitmq.PlanCreate:     "+",
+		rabbitmq.PlanEnsure:     "~",
+		rabbitmq.PlanMatch:      "=",
+		rabbitmq.PlanUnverified: "?",
+		rabbitmq.PlanConflict:   "!",
+	}
+	counts := make(map[rabbitmq.PlanAction]int)
+
+	for _, entry := range entries {
+		counts[entry.Action]++
+		_, _ = fmt.Fprintf(out, "%s %-8s %s", symbols[entry.Action], entry.Kind, entry.Name)
+		if len(entry.Detail) > 0 {
+			_, _ = fmt.Fprintf(out, " [%s]", entry.Detail)
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+
+	_, _ = fmt.Fprintf(out, "\nPlan: %d to create, %d to ensure, %d matching, %d unverified, %d conflicting\n",
+		counts[rabbitmq.PlanCreate], counts[rabbitmq.PlanEnsure], counts[rabbitmq.PlanMatch], counts[rabbitmq.PlanUnverified], counts[rabbitmq.PlanConflict])
+
+	return counts[rabbitmq.PlanConflict]
+}
@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintPlan(t *testing.T) {
+	out := new(bytes.Buffer)
+
+	conflicts := printPlan([]rabbitmq.PlanEntry{
+		{Kind: "exchange", Name: "Dax", Action: rabbitmq.PlanMatch},
+		{Kind: "queue", Name: "OpenFaaS_Dax_BMW", Action: rabbitmq.PlanConflict, Detail: "PRECONDITION_FAILED"},
+		{Kind: "binding", Name: "Dax -> OpenFaaS_Dax_BMW", Action: rabbitmq.PlanEnsure},
+	}, out)
+
+	assert.Equal(t, 1, conflicts)
+	assert.Contains(t, out.String(), "= exchange Dax\n")
+	assert.Contains(t, out.String(), "! queue    OpenFaaS_Dax_BMW [PRECONDITION_FAILED]\n")
+	assert.Contains(t, out.String(), "Plan: 0 to create, 1 to ensure, 1 matching, 0 unverified, 1 conflicting")
+}
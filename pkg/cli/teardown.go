@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/spf13/afero"
+)
+
+// Teardown deletes the queues and optionally the exchanges the connector manages for the topology. With --dry-run
+// it only lists them without connecting to RabbitMQ.
+func Teardown(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	var opts rabbitmq.TeardownOptions
+
+	flags := flag.NewFlagSet("teardown", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&opts.IfEmpty, "if-empty", false, "only delete queues without messages")
+	flags.BoolVar(&opts.IfUnused, "if-unused", false, "only delete queues without consumers and exchanges without bindings")
+	flags.BoolVar(&opts.Exchanges, "exchanges", false, "also delete exchanges with declare: true")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "only list what would be deleted")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: rmq-connector teardown [flags] [topology file]")
+		_, _ = fmt.Fprintln(stderr, "Deletes the queues and bindings of the topology, defaults to PATH_TO_TOPOLOGY.")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if opts.DryRun {
+		path := flags.Arg(0)
+		if len(path) == 0 {
+			path = os.Getenv("PATH_TO_TOPOLOGY")
+		}
+
+		topology, err := config.ReadTopology(fs, path, false)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Invalid topology: %s\n", err)
+			return ExitFailure
+		}
+
+		steps, _ := rabbitmq.Teardown(nil, topology, opts)
+		printTeardown(steps, stdout)
+		return ExitOK
+	}
+
+	conf, err := config.NewConfig(fs)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid config: %s\n", err)
+		return ExitFailure
+	}
+
+	topology := conf.Topology
+	if flags.NArg() > 0 {
+		topology, err = config.ReadTopology(fs, flags.Arg(0), conf.StrictTopology)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Invalid topology: %s\n", err)
+			return ExitFailure
+		}
+	}
+
+	manager, err := connect(conf)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Could not connect to %s: %s\n", conf.RabbitSanitizedURL, err)
+		return ExitFailure
+	}
+	defer manager.Disconnect()
+
+	steps, err := rabbitmq.Teardown(manager, topology, opts)
+	printTeardown(steps, stdout)
+
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Teardown aborted due to %s\n", err)
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+func printTeardown(steps []rabbitmq.TeardownStep, out io.Writer) {
+	counts := make(map[rabbitmq.TeardownStatus]int)
+
+	for _, step := range steps {
+		counts[step.Status]++
+		_, _ = fmt.Fprintf(out, "%-7s %-8s %s", step.Status, step.Kind, step.Name)
+		if len(step.Detail) > 0 {
+			_, _ = fmt.Fprintf(out, " [%s]", step.Detail)
+		}
+		_, _ = fmt.Fprintln(out)
+	}
+
+	_, _ = fmt.Fprintf(out, "\nTeardown: %d planned, %d deleted, %d skipped\n",
+		counts[rabbitmq.TeardownPlanned], counts[rabbitmq.TeardownDeleted], counts[rabbitmq.TeardownSkipped])
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeardown(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "topology.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  declare: true`), 0644)
+
+	t.Run("Should list queues and exchanges during dry run", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"teardown", "--dry-run", "--exchanges", "topology.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code)
+		assert.Contains(t, stdout.String(), "planned queue    OpenFaaS_AEx_Foo")
+		assert.Contains(t, stdout.String(), "planned exchange AEx")
+		assert.Contains(t, stdout.String(), "Teardown: 2 planned, 0 deleted, 0 skipped")
+	})
+
+	t.Run("Should fail for missing topology", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"teardown", "--dry-run", "missing.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), "Invalid topology")
+	})
+}
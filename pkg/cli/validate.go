@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/spf13/afero"
+)
+
+// Validate strictly validates the topology passed as arguments, falling back to PATH_TO_TOPOLOGY. Each argument is
+// resolved like PATH_TO_TOPOLOGY, so it may be a file, a directory or a glob pattern, and the overlays listed in
+// TOPOLOGY_OVERLAY are applied on top. Every problem is reported with its line and the command fails if any problem was found.
+func Validate(fs afero.Fs, args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: rmq-connector validate [topology paths...]")
+		_, _ = fmt.Fprintln(stderr, "Validates the provided topology files, directories or patterns, defaults to PATH_TO_TOPOLOGY.")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if path, ok := os.LookupEnv("PATH_TO_TOPOLOGY"); ok {
+			paths = []string{path}
+		} else {
+			flags.Usage()
+			return ExitUsage
+		}
+	}
+
+	code := ExitOK
+	for _, path := range paths {
+		reports, err := config.ValidateTopology(fs, path)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "%s: %s\n", path, err)
+			code = ExitFailure
+			continue
+		}
+
+		for _, report := range reports {
+			if !printReport(report, stdout, stderr) {
+				code = ExitFailure
+			}
+		}
+	}
+
+	return code
+}
+
+// printReport prints the problems of the report and reports whether there were none
+func printReport(report config.TopologyReport, stdout io.Writer, stderr io.Writer) bool {
+	for _, problem := range report.Problems {
+		if problem.Line > 0 {
+			_, _ = fmt.Fprintf(stderr, "%s:%d: %s\n", report.Path, problem.Line, problem.Message)
+		} else {
+			_, _ = fmt.Fprintf(stderr, "%s: %s\n", report.Path, problem.Message)
+		}
+	}
+
+	switch {
+	case len(report.Problems) > 0:
+		_, _ = fmt.Fprintf(stderr, "%s: found %d problem(s)\n", report.Path, len(report.Problems))
+		return false
+	case report.Definitions:
+		_, _ = fmt.Fprintf(stdout, "%s: definitions export is not validated against the topology schema\n", report.Path)
+	case report.Overlay:
+		_, _ = fmt.Fprintf(stdout, "%s: overlay applies to the topology\n", report.Path)
+	default:
+		_, _ = fmt.Fprintf(stdout, "%s: topology is valid\n", report.Path)
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "valid.yaml", []byte(`- name: AEx
+  topics: [Foo]`), 0644)
+	_ = afero.WriteFile(fs, "invalid.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  type: fanout`), 0644)
+	_ = afero.WriteFile(fs, "invalid.json", []byte(`[
+  {"name": "AEx", "topics": ["Foo"], "durable": "yes"}
+]`), 0644)
+	_ = afero.WriteFile(fs, "definitions.json", []byte(`{"exchanges": []}`), 0644)
+
+	t.Run("Should succeed for valid topology", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate", "valid.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code)
+		assert.Contains(t, stdout.String(), "valid.yaml: topology is valid")
+		assert.Empty(t, stderr.String())
+	})
+
+	t.Run("Should fail and report problems with file and line", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate", "valid.yaml", "invalid.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), `invalid.yaml:3: unknown exchange type "fanout"`)
+		assert.Contains(t, stderr.String(), "invalid.yaml: found 1 problem(s)")
+	})
+
+	t.Run("Should validate json topology", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate", "invalid.json", "definitions.json"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), `invalid.json:2: field "durable" has to be either true or false`)
+		assert.Contains(t, stdout.String(), "definitions.json: definitions export is not validated")
+	})
+
+	t.Run("Should validate every file of directory and report exchanges defined in multiple files", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "topology/billing.yaml", []byte(`- name: Billing
+  topics: [Foo]`), 0644)
+		_ = afero.WriteFile(fs, "topology/orders.yaml", []byte(`- name: Billing
+  topics: [Bar]`), 0644)
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate", "topology"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stdout.String(), "topology/billing.yaml: topology is valid")
+		assert.Contains(t, stderr.String(), "topology/orders.yaml: exchange Billing is defined in both topology/billing.yaml and topology/orders.yaml")
+	})
+
+	t.Run("Should validate interpolated topology", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "interpolated.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  type: ${TEST_TYPE:-direct}
+  durable: ${TEST_DURABLE}`), 0644)
+		os.Setenv("TEST_DURABLE", "true")
+		defer os.Unsetenv("TEST_DURABLE")
+
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		code := Run(fs, []string{"validate", "interpolated.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code)
+		assert.Contains(t, stdout.String(), "interpolated.yaml: topology is valid")
+
+		os.Setenv("TEST_TYPE", "fanout")
+		defer os.Unsetenv("TEST_TYPE")
+
+		stdout, stderr = new(bytes.Buffer), new(bytes.Buffer)
+		code = Run(fs, []string{"validate", "interpolated.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), `interpolated.yaml:3: unknown exchange type "fanout"`)
+	})
+
+	t.Run("Should report variables without default that are not set", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate", "interpolated.yaml"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), "interpolated.yaml: environment variable(s) TEST_DURABLE referenced without default are not set")
+	})
+
+	t.Run("Should fall back to PATH_TO_TOPOLOGY", func(t *testing.T) {
+		os.Setenv("PATH_TO_TOPOLOGY", "missing.yaml")
+		defer os.Unsetenv("PATH_TO_TOPOLOGY")
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"validate"}, stdout, stderr)
+
+		assert.Equal(t, ExitFailure, code)
+		assert.Contains(t, stderr.String(), "missing.yaml")
+	})
+
+	t.Run("Should print schema", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"schema"}, stdout, stderr)
+
+		assert.Equal(t, ExitOK, code)
+		assert.Contains(t, stdout.String(), `"title": "rabbitmq-connector topology"`)
+		assert.Contains(t, stderr.String(), "only rejects topologies that do not match this schema with TOPOLOGY_STRICT enabled")
+	})
+
+	t.Run("Should print usage for unknown commands", func(t *testing.T) {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+		code := Run(fs, []string{"unknown"}, stdout, stderr)
+
+		assert.Equal(t, ExitUsage, code)
+		assert.Contains(t, stderr.String(), "Usage: rmq-connector")
+	})
+}
@@ -6,16 +6,20 @@
 package config
 
 import (
+	"crypto/rand"
 	"crypto/tls"
-	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
 	internal "github.com/Templum/rabbitmq-connector/pkg/types"
 	"github.com/openfaas/connector-sdk/types"
 	"github.com/openfaas/faas-provider/auth"
@@ -24,34 +28,98 @@ import (
 
 // Controller is the config needed for the connector
 type Controller struct {
-	GatewayURL          string
-	RabbitConnectionURL string
-	RabbitSanitizedURL  string
+	GatewayURL           string
+	RabbitConnectionURLs []string
+	RabbitSanitizedURL   string
+	NodeStrategy         rabbitmq.NodeStrategy
+	Management           ManagementConfig
+
+	IsTLSEnabled             bool
+	TLSConfig                *tls.Config
+	TLSWatchInterval         time.Duration
+	TLSReconnectBeforeExpiry time.Duration
+	AuthMechanism            string
+	CredentialsWatchInterval time.Duration
+
+	Topology              internal.Topology
+	TopologyPath          string
+	TopologyWatchInterval time.Duration
+	StrictTopology        bool
+
+	DynamicBindings         bool
+	DynamicExchange         string
+	DynamicQueueGracePeriod time.Duration
+
+	Heartbeat      time.Duration
+	ChannelMax     int
+	FrameSize      int
+	DialTimeout    time.Duration
+	Locale         string
+	ConnectionName string
+	InstanceID     string
+
+	Reconnect  internal.ReconnectPolicy
+	HealthAddr string
+
+	TopicRefreshTime         time.Duration
+	BasicAuth                *auth.BasicAuthCredentials
+	BasicAuthSecretPath      string
+	GatewayAuth              string
+	GatewayAuthWatchInterval time.Duration
+	GatewayAuthPause         time.Duration
+	GatewayTokenFile         string
+	OAuth                    OAuthConfig
+	InsecureSkipVerify       bool
+	GatewayTLSConfig         *tls.Config
+	MaxClientsPerHost        int
+	RequestTimeout           time.Duration
+	Retry                    internal.RetryPolicy
+}
 
-	IsTLSEnabled bool
-	TLSConfig    *tls.Config
+// Supported kinds of authentication towards the gateway
+const (
+	GatewayAuthNone              = "none"
+	GatewayAuthBasic             = "basic"
+	GatewayAuthBearer            = "bearer"
+	GatewayAuthClientCredentials = "client-credentials"
+)
 
-	Topology internal.Topology
+// OAuthConfig describes the OAuth2 client credentials flow used to obtain tokens for the gateway
+type OAuthConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Audience     string
+	TLSConfig    *tls.Config
+}
 
-	TopicRefreshTime   time.Duration
-	BasicAuth          *auth.BasicAuthCredentials
-	InsecureSkipVerify bool
-	MaxClientsPerHost  int
+// ManagementConfig describes the access to the Rabbit MQ management API, which is served independently of the AMQP
+// listener. Without credentials of its own the ones of the connection url are used.
+type ManagementConfig struct {
+	URL       string
+	User      string
+	Password  string
+	TLSConfig *tls.Config
 }
 
 // NewConfig reads the connector config from environment variables and further validates them,
 // in some cases it will leverage default values.
 func NewConfig(fs afero.Fs) (*Controller, error) {
+	if err := validateSettings(); err != nil {
+		return nil, err
+	}
+
 	gatewayURL, err := getOpenFaaSUrl()
 	if err != nil {
 		return nil, err
 	}
 
-	var rabbitURL, sanitizedURL string
+	var rabbitURLs, sanitizedURLs []string
 	var tlsConfig *tls.Config = nil
 
-	if readFromEnv(envUseTLS, "false") == "true" {
-		rabbitURL, sanitizedURL, err = getRabbitMQConnectionURL(true)
+	if useTLS, _ := strconv.ParseBool(readFromEnv(envUseTLS, "false")); useTLS {
+		rabbitURLs, sanitizedURLs, err = getRabbitMQConnectionURLs(fs, true)
 
 		if cfg, confErr := generateTlsConfig(fs); confErr == nil {
 			tlsConfig = cfg
@@ -60,42 +128,112 @@ func NewConfig(fs afero.Fs) (*Controller, error) {
 		}
 
 	} else {
-		rabbitURL, sanitizedURL, err = getRabbitMQConnectionURL(false)
+		rabbitURLs, sanitizedURLs, err = getRabbitMQConnectionURLs(fs, false)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	authMechanism, err := getAuthMechanism(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	skipVerify, err := strconv.ParseBool(readFromEnv(envSkipVerify, "false"))
 	if err != nil {
 		skipVerify = false
 	}
 
-	topology, err := getTopology(fs)
+	gatewayTLSConfig, err := generateGatewayTlsConfig(fs)
 	if err != nil {
 		return nil, err
 	}
 
-	maxClients, err := getMaxClients()
+	basicAuth := types.GetCredentials()
+	gatewayAuth, err := getGatewayAuth(basicAuth)
 	if err != nil {
-		maxClients = 256
+		return nil, err
 	}
 
-	return &Controller{
-		GatewayURL: gatewayURL,
-		BasicAuth:  types.GetCredentials(),
+	oauth, err := getOAuthConfig(fs, gatewayAuth)
+	if err != nil {
+		return nil, err
+	}
 
-		TLSConfig: tlsConfig,
+	management, err := getManagementConfig(fs)
+	if err != nil {
+		return nil, err
+	}
 
-		RabbitConnectionURL: rabbitURL,
-		RabbitSanitizedURL:  sanitizedURL,
+	strictTopology, err := strconv.ParseBool(readFromEnv(envStrictTopology, "false"))
+	if err != nil {
+		strictTopology = false
+	}
+
+	topologyPath := readFromEnv(envPathToTopology, "")
+	topology, err := ReadTopology(fs, topologyPath, strictTopology)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicBindings, err := strconv.ParseBool(readFromEnv(envDynamicBindings, "false"))
+	if err != nil {
+		dynamicBindings = false
+	}
+
+	maxClients, err := getMaxClients()
+	if err != nil {
+		maxClients = 256
+	}
 
-		Topology: topology,
+	return &Controller{
+		GatewayURL:               gatewayURL,
+		BasicAuth:                basicAuth,
+		BasicAuthSecretPath:      getBasicAuthSecretPath(basicAuth),
+		GatewayAuth:              gatewayAuth,
+		GatewayAuthWatchInterval: getDuration(envGatewayAuthWatch, "30s"),
+		GatewayAuthPause:         getDuration(envGatewayAuthPause, "10s"),
+		GatewayTokenFile:         readFromEnv(envGatewayTokenFile, ""),
+		OAuth:                    oauth,
+
+		TLSConfig:                tlsConfig,
+		TLSWatchInterval:         getDuration(envTLSWatchInterval, "1m"),
+		TLSReconnectBeforeExpiry: getDuration(envTLSReconnectBeforeExpiry, "0s"),
+		AuthMechanism:            authMechanism,
+		CredentialsWatchInterval: getDuration(envCredentialsWatch, "1m"),
+
+		RabbitConnectionURLs: rabbitURLs,
+		RabbitSanitizedURL:   strings.Join(sanitizedURLs, ", "),
+		NodeStrategy:         getNodeStrategy(),
+		Management:           management,
+
+		Topology:              topology,
+		TopologyPath:          topologyPath,
+		TopologyWatchInterval: getTopologyWatchInterval(),
+		StrictTopology:        strictTopology,
+
+		DynamicBindings:         dynamicBindings,
+		DynamicExchange:         readFromEnv(envDynamicExchange, "amq.topic"),
+		DynamicQueueGracePeriod: getDynamicQueueGracePeriod(),
+
+		Heartbeat:      getDuration(envHeartbeat, "10s"),
+		ChannelMax:     getNonNegativeInt(envChannelMax, "0"),
+		FrameSize:      getNonNegativeInt(envFrameSize, "0"),
+		DialTimeout:    getDuration(envDialTimeout, "30s"),
+		Locale:         readFromEnv(envLocale, "en_US"),
+		ConnectionName: readFromEnv(envConnectionName, defaultConnectionName()),
+		InstanceID:     readFromEnv(envInstanceID, generateInstanceID()),
+
+		Reconnect:  getReconnectPolicy(),
+		HealthAddr: readFromEnv(envHealthAddr, ""),
 
 		TopicRefreshTime:   getRefreshTime(),
 		InsecureSkipVerify: skipVerify,
+		GatewayTLSConfig:   gatewayTLSConfig,
 		MaxClientsPerHost:  maxClients,
+		RequestTimeout:     getDuration(envRequestTimeout, "30s"),
+		Retry:              getRetryPolicy(),
 	}, nil
 }
 
@@ -103,20 +241,95 @@ const (
 	envFaaSGwURL         = "OPEN_FAAS_GW_URL"
 	envSkipVerify        = "INSECURE_SKIP_VERIFY"
 	envMaxClientsPerHost = "MAX_CLIENT_PER_HOST"
+	envRequestTimeout    = "REQ_TIMEOUT"
+
+	envRetryMaxAttempts = "INVOKE_RETRY_MAX_ATTEMPTS"
+	envRetryBaseDelay   = "INVOKE_RETRY_BASE_DELAY"
+	envRetryMaxDelay    = "INVOKE_RETRY_MAX_DELAY"
+	envRetryJitter      = "INVOKE_RETRY_JITTER"
+	envRetryBudget      = "INVOKE_RETRY_BUDGET"
+	envRetryClasses     = "INVOKE_RETRY_CLASSES"
+
+	defaultRetryClasses = "transport,server-error,timeout"
+
+	envGatewayCACert     = "OPEN_FAAS_GW_CA_CERT_PATH"
+	envGatewayCert       = "OPEN_FAAS_GW_CERT_PATH"
+	envGatewayKey        = "OPEN_FAAS_GW_KEY_PATH"
+	envGatewayServerName = "OPEN_FAAS_GW_SERVER_NAME"
+	envGatewayMinVersion = "OPEN_FAAS_GW_TLS_MIN_VERSION"
+
+	envGatewayAuth       = "OPEN_FAAS_GW_AUTH"
+	envGatewayTokenFile  = "OPEN_FAAS_GW_TOKEN_FILE"
+	envGatewayAuthWatch  = "OPEN_FAAS_GW_AUTH_WATCH_INTERVAL"
+	envGatewayAuthPause  = "OPEN_FAAS_GW_AUTH_PAUSE"
+	envSecretMountPath   = "secret_mount_path"
+	envOAuthTokenURL     = "OPEN_FAAS_GW_OAUTH_TOKEN_URL"
+	envOAuthClientID     = "OPEN_FAAS_GW_OAUTH_CLIENT_ID"
+	envOAuthClientSecret = "OPEN_FAAS_GW_OAUTH_CLIENT_SECRET"
+	envOAuthScopes       = "OPEN_FAAS_GW_OAUTH_SCOPES"
+	envOAuthAudience     = "OPEN_FAAS_GW_OAUTH_AUDIENCE"
+	envOAuthCACert       = "OPEN_FAAS_GW_OAUTH_CA_CERT_PATH"
+	envOAuthCert         = "OPEN_FAAS_GW_OAUTH_CERT_PATH"
+	envOAuthKey          = "OPEN_FAAS_GW_OAUTH_KEY_PATH"
+	envOAuthMinVersion   = "OPEN_FAAS_GW_OAUTH_TLS_MIN_VERSION"
 
 	envUseTLS           = "TLS_ENABLED"
 	envPathToCACert     = "TLS_CA_CERT_PATH"
 	envPathToServerCert = "TLS_SERVER_CERT_PATH"
 	envPathToServerKey  = "TLS_SERVER_KEY_PATH"
+	envTLSServerName    = "TLS_SERVER_NAME"
+	envTLSMinVersion    = "TLS_MIN_VERSION"
+	envTLSCipherSuites  = "TLS_CIPHER_SUITES"
+	envTLSSkipVerify    = "TLS_INSECURE_SKIP_VERIFY"
+
+	envTLSWatchInterval         = "TLS_WATCH_INTERVAL"
+	envTLSReconnectBeforeExpiry = "TLS_RECONNECT_BEFORE_EXPIRY"
 
 	envRabbitUser  = "RMQ_USER"
 	envRabbitPass  = "RMQ_PASS"
 	envRabbitHost  = "RMQ_HOST"
 	envRabbitPort  = "RMQ_PORT"
 	envRabbitVHost = "RMQ_VHOST"
-
-	envPathToTopology = "PATH_TO_TOPOLOGY"
-	envRefreshTime    = "TOPIC_MAP_REFRESH_TIME"
+	envRabbitURL   = "RMQ_URL"
+	envRabbitURLs  = "RMQ_URLS"
+	envRabbitAuth  = "RMQ_AUTH_MECHANISM"
+
+	envCredentialsWatch = "RMQ_CREDENTIALS_WATCH_INTERVAL"
+
+	envHeartbeat      = "RMQ_HEARTBEAT"
+	envChannelMax     = "RMQ_CHANNEL_MAX"
+	envFrameSize      = "RMQ_FRAME_SIZE"
+	envDialTimeout    = "RMQ_DIAL_TIMEOUT"
+	envLocale         = "RMQ_LOCALE"
+	envConnectionName = "RMQ_CONNECTION_NAME"
+	envInstanceID     = "INSTANCE_ID"
+
+	envNodeStrategy         = "RMQ_NODE_STRATEGY"
+	envManagementURL        = "RMQ_MANAGEMENT_URL"
+	envManagementUser       = "RMQ_MANAGEMENT_USER"
+	envManagementPass       = "RMQ_MANAGEMENT_PASS"
+	envManagementCACert     = "RMQ_MANAGEMENT_CA_CERT_PATH"
+	envManagementCert       = "RMQ_MANAGEMENT_CERT_PATH"
+	envManagementKey        = "RMQ_MANAGEMENT_KEY_PATH"
+	envManagementMinVersion = "RMQ_MANAGEMENT_TLS_MIN_VERSION"
+
+	envPathToTopology      = "PATH_TO_TOPOLOGY"
+	envTopologyWatchPeriod = "TOPOLOGY_WATCH_INTERVAL"
+	envStrictTopology      = "TOPOLOGY_STRICT"
+	envDefinitionsVHost    = "TOPOLOGY_DEFINITIONS_VHOST"
+	envDefinitionsPrefix   = "TOPOLOGY_DEFINITIONS_PREFIX"
+	envTopologyOverlay     = "TOPOLOGY_OVERLAY"
+	envRefreshTime         = "TOPIC_MAP_REFRESH_TIME"
+
+	envDynamicBindings    = "DYNAMIC_BINDINGS"
+	envDynamicExchange    = "DYNAMIC_BINDINGS_EXCHANGE"
+	envDynamicGracePeriod = "DYNAMIC_BINDINGS_DELETE_AFTER"
+
+	envReconnectMaxAttempts = "RECONNECT_MAX_ATTEMPTS"
+	envReconnectBaseDelay   = "RECONNECT_BASE_DELAY"
+	envReconnectMaxDelay    = "RECONNECT_MAX_DELAY"
+	envReconnectJitter      = "RECONNECT_JITTER"
+	envHealthAddr           = "HEALTH_ADDR"
 )
 
 func getMaxClients() (int, error) {
@@ -132,88 +345,267 @@ func getOpenFaaSUrl() (string, error) {
 	return url, nil
 }
 
-func generateTlsConfig(fs afero.Fs) (*tls.Config, error) {
-	caCertPath := readFromEnv(envPathToCACert, "")
-	if exists, err := afero.Exists(fs, caCertPath); !exists {
-		return nil, fmt.Errorf("Ca Cert at %s does not exist or is not accessible %s", caCertPath, err)
+// getGatewayAuth returns how to authenticate towards the gateway, which defaults to basic auth if it is enabled
+func getGatewayAuth(basicAuth *auth.BasicAuthCredentials) (string, error) {
+	fallback := GatewayAuthNone
+	if basicAuth != nil {
+		fallback = GatewayAuthBasic
 	}
 
-	serverCertPath := readFromEnv(envPathToServerCert, "")
-	if exists, err := afero.Exists(fs, serverCertPath); !exists {
-		return nil, fmt.Errorf("Server Cert at %s does not exist or is not accessible %s", serverCertPath, err)
+	switch mode := strings.ToLower(readFromEnv(envGatewayAuth, fallback)); mode {
+	case GatewayAuthNone:
+		return mode, nil
+	case GatewayAuthBasic:
+		if basicAuth == nil {
+			return "", fmt.Errorf("Provided gateway auth %s requires basic_auth to be enabled", mode)
+		}
+		return mode, nil
+	case GatewayAuthBearer:
+		if len(readFromEnv(envGatewayTokenFile, "")) == 0 {
+			return "", fmt.Errorf("Provided gateway auth %s requires %s", mode, envGatewayTokenFile)
+		}
+		return mode, nil
+	case GatewayAuthClientCredentials:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Provided gateway auth %s is not one of %s, %s, %s or %s", mode, GatewayAuthNone, GatewayAuthBasic, GatewayAuthBearer, GatewayAuthClientCredentials)
 	}
+}
 
-	serverKeyPath := readFromEnv(envPathToServerKey, "")
-	if exists, err := afero.Exists(fs, serverKeyPath); !exists {
-		return nil, fmt.Errorf("Server Key at %s does not exist or is not accessible %s", serverKeyPath, err)
+// getBasicAuthSecretPath returns the path the basic auth credentials were read from, so they can be re-read once rotated
+func getBasicAuthSecretPath(basicAuth *auth.BasicAuthCredentials) string {
+	if basicAuth == nil {
+		return ""
 	}
 
-	// At this point we know every required file is present and accessible
-	cfg := new(tls.Config)
-	cfg.RootCAs = x509.NewCertPool()
+	return readFromEnv(envSecretMountPath, "")
+}
 
-	if ca, err := afero.ReadFile(fs, caCertPath); err == nil {
-		cfg.RootCAs.AppendCertsFromPEM(ca)
-	} else {
-		return nil, err
+func getManagementConfig(fs afero.Fs) (ManagementConfig, error) {
+	conf := ManagementConfig{URL: readFromEnv(envManagementURL, "")}
+	if len(conf.URL) == 0 {
+		return conf, nil
 	}
 
-	if cert, err := afero.ReadFile(fs, serverCertPath); err == nil {
-		if key, err := afero.ReadFile(fs, serverKeyPath); err == nil {
-			if cert, err := tls.X509KeyPair(cert, key); err == nil {
-				cfg.Certificates = append(cfg.Certificates, cert)
-			} else {
-				return nil, err
-			}
-		} else {
-			return nil, err
-		}
-	} else {
-		return nil, err
+	var err error
+	conf.User, err = readSecret(fs, envManagementUser, "")
+	if err != nil {
+		return ManagementConfig{}, err
+	}
+
+	conf.Password, err = readSecret(fs, envManagementPass, "")
+	if err != nil {
+		return ManagementConfig{}, err
+	}
+
+	if (len(conf.User) == 0) != (len(conf.Password) == 0) {
+		return ManagementConfig{}, fmt.Errorf("%s and %s have to be provided together", envManagementUser, envManagementPass)
+	}
+
+	conf.TLSConfig, err = generateManagementTlsConfig(fs)
+	if err != nil {
+		return ManagementConfig{}, err
+	}
+
+	return conf, nil
+}
+
+func getOAuthConfig(fs afero.Fs, gatewayAuth string) (OAuthConfig, error) {
+	if gatewayAuth != GatewayAuthClientCredentials {
+		return OAuthConfig{}, nil
+	}
+
+	clientID, err := readSecret(fs, envOAuthClientID, "")
+	if err != nil {
+		return OAuthConfig{}, err
+	}
+
+	clientSecret, err := readSecret(fs, envOAuthClientSecret, "")
+	if err != nil {
+		return OAuthConfig{}, err
+	}
+
+	conf := OAuthConfig{
+		TokenURL:     readFromEnv(envOAuthTokenURL, ""),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Audience:     readFromEnv(envOAuthAudience, ""),
+	}
+	conf.Scopes = strings.FieldsFunc(readFromEnv(envOAuthScopes, ""), func(r rune) bool { return r == ',' || r == ' ' })
+
+	if !strings.HasPrefix(conf.TokenURL, "http://") && !strings.HasPrefix(conf.TokenURL, "https://") {
+		return OAuthConfig{}, fmt.Errorf("Provided token url %s does not include the protocol http / https", conf.TokenURL)
+	}
+
+	if len(conf.ClientID) == 0 || len(conf.ClientSecret) == 0 {
+		return OAuthConfig{}, fmt.Errorf("Provided gateway auth %s requires %s and %s", gatewayAuth, envOAuthClientID, envOAuthClientSecret)
+	}
+
+	conf.TLSConfig, err = generateOAuthTlsConfig(fs)
+	if err != nil {
+		return OAuthConfig{}, err
 	}
 
-	return cfg, nil
+	return conf, nil
 }
 
-// getRabbitMQConnectionURL returns the fully build url and the sanitized version for usage in logging
-func getRabbitMQConnectionURL(isTls bool) (string, string, error) {
-	user := readFromEnv(envRabbitUser, "")
-	pass := readFromEnv(envRabbitPass, "")
-	host := readFromEnv(envRabbitHost, "localhost")
-	port := readFromEnv(envRabbitPort, "5672")
+// getRabbitMQConnectionURLs returns the fully build url and the sanitized version for usage in logging of every node.
+// Nodes are either listed as complete urls in RMQ_URLS, as a single complete url in RMQ_URL or as comma separated hosts in
+// RMQ_HOST, which default to RMQ_PORT. Credentials and vhost of the latter are escaped, so they may contain any character.
+// Urls and credentials may also be read from files via the _FILE variants of their envs.
+func getRabbitMQConnectionURLs(fs afero.Fs, isTls bool) ([]string, []string, error) {
+	rawURLs, err := readSecret(fs, envRabbitURLs, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawURL, err := readSecret(fs, envRabbitURL, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case len(rawURLs) > 0 && len(rawURL) > 0:
+		return nil, nil, fmt.Errorf("Provided %s and %s, only one of them can be used", envRabbitURL, envRabbitURLs)
+	case len(rawURLs) > 0:
+		return parseRabbitMQURLs(rawURLs)
+	case len(rawURL) > 0:
+		connectionURL, sanitizedURL, err := parseRabbitMQURL(rawURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []string{connectionURL}, []string{sanitizedURL}, nil
+	}
+
+	user, err := readSecret(fs, envRabbitUser, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pass, err := readSecret(fs, envRabbitPass, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts := readFromEnv(envRabbitHost, "localhost")
+	defaultPort := readFromEnv(envRabbitPort, "5672")
 	vhost := readFromEnv(envRabbitVHost, "")
 	protocol := "amqp"
 	if isTls {
 		protocol = "amqps"
 	}
 
-	parsedPort, err := strconv.Atoi(port)
+	var credentials *url.Userinfo
+	if user != "" || pass != "" {
+		credentials = url.UserPassword(user, pass)
+	}
 
-	if err != nil {
-		message := fmt.Sprintf("Provided port %s is not a valid port", port)
-		return "", "", errors.New(message)
+	var urls, sanitizedURLs []string
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		port := defaultPort
+
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host, port = h, p
+		}
+
+		parsedPort, err := strconv.Atoi(port)
+
+		if err != nil {
+			message := fmt.Sprintf("Provided port %s is not a valid port", port)
+			return nil, nil, errors.New(message)
+		}
+
+		if parsedPort <= 0 || parsedPort > 65535 {
+			message := fmt.Sprintf("Provided port %s is outside of the allowed port range", port)
+			return nil, nil, errors.New(message)
+		}
+
+		address := net.JoinHostPort(host, port)
+		sanitizedURLs = append(sanitizedURLs, buildRabbitMQURL(protocol, address, vhost, nil))
+		urls = append(urls, buildRabbitMQURL(protocol, address, vhost, credentials))
 	}
 
-	if parsedPort <= 0 || parsedPort > 65535 {
-		message := fmt.Sprintf("Provided port %s is outside of the allowed port range", port)
-		return "", "", errors.New(message)
+	return urls, sanitizedURLs, nil
+}
+
+// buildRabbitMQURL escapes the credentials and the vhost, which RabbitMQ expects as the first segment of the path. Hence
+// the default vhost / is written as %2F, while an empty vhost leaves the choice to the client library.
+func buildRabbitMQURL(protocol string, address string, vhost string, credentials *url.Userinfo) string {
+	target := url.URL{
+		Scheme:  protocol,
+		User:    credentials,
+		Host:    address,
+		Path:    "/" + vhost,
+		RawPath: "/" + url.PathEscape(vhost),
 	}
 
-	if user == "" && pass == "" {
-		return fmt.Sprintf("%s://%s:%s/%s", protocol, host, port, vhost), fmt.Sprintf("%s://%s:%s/%s", protocol, host, port, vhost), nil
+	return target.String()
+}
+
+func parseRabbitMQURLs(rawURLs string) ([]string, []string, error) {
+	var urls, sanitizedURLs []string
+
+	for _, rawURL := range strings.Split(rawURLs, ",") {
+		connectionURL, sanitizedURL, err := parseRabbitMQURL(rawURL)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		urls = append(urls, connectionURL)
+		sanitizedURLs = append(sanitizedURLs, sanitizedURL)
 	}
 
-	return fmt.Sprintf("%s://%s:%s@%s:%s/%s", protocol, user, pass, host, port, vhost), fmt.Sprintf("%s://%s:%s/%s", protocol, host, port, vhost), nil
+	return urls, sanitizedURLs, nil
 }
 
-func getTopology(fs afero.Fs) (internal.Topology, error) {
-	path := readFromEnv(envPathToTopology, ".")
+// parseRabbitMQURL validates a complete url, which is expected to be escaped already, and returns it with the sanitized version
+func parseRabbitMQURL(rawURL string) (string, string, error) {
+	rawURL = strings.TrimSpace(rawURL)
 
-	if info, err := fs.Stat(path); os.IsNotExist(err) || !strings.HasSuffix(info.Name(), "yaml") {
-		return internal.Topology{}, errors.New("provided topology is either non existing or does not end with .yaml")
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "amqp" && parsed.Scheme != "amqps") || len(parsed.Host) == 0 {
+		return "", "", fmt.Errorf("Provided url %s is not a valid amqp:// or amqps:// url", redact(rawURL))
 	}
 
-	return internal.ReadTopologyFromFile(fs, path)
+	parsed.User = nil
+	return rawURL, parsed.String(), nil
+}
+
+// getAuthMechanism returns the SASL mechanism used for authenticating, EXTERNAL relies on the client certificate
+// and therefore requires TLS with a client certificate.
+func getAuthMechanism(tlsConfig *tls.Config) (string, error) {
+	mechanism := strings.ToUpper(readFromEnv(envRabbitAuth, rabbitmq.AuthPlain))
+
+	switch mechanism {
+	case rabbitmq.AuthPlain:
+		return mechanism, nil
+	case rabbitmq.AuthExternal:
+		if tlsConfig == nil || len(tlsConfig.Certificates) == 0 {
+			return "", fmt.Errorf("Provided auth mechanism %s requires %s with a client certificate", mechanism, envUseTLS)
+		}
+		return mechanism, nil
+	default:
+		return "", fmt.Errorf("Provided auth mechanism %s is neither %s nor %s", mechanism, rabbitmq.AuthPlain, rabbitmq.AuthExternal)
+	}
+}
+
+// redact strips everything up to the host from an url that might contain credentials
+func redact(rawURL string) string {
+	if at := strings.LastIndex(rawURL, "@"); at >= 0 {
+		return "***" + rawURL[at:]
+	}
+	return rawURL
+}
+
+func getNodeStrategy() rabbitmq.NodeStrategy {
+	switch strategy := rabbitmq.NodeStrategy(strings.ToLower(readFromEnv(envNodeStrategy, string(rabbitmq.RoundRobin)))); strategy {
+	case rabbitmq.RoundRobin, rabbitmq.Shuffle:
+		return strategy
+	default:
+		log.Printf("Provided Node Strategy %s is neither round-robin nor shuffle. Falling back to round-robin", strategy)
+		return rabbitmq.RoundRobin
+	}
 }
 
 func getRefreshTime() time.Duration {
@@ -226,6 +618,167 @@ func getRefreshTime() time.Duration {
 	return refreshTime
 }
 
+func getTopologyWatchInterval() time.Duration {
+	interval, err := time.ParseDuration(readFromEnv(envTopologyWatchPeriod, "10s"))
+	if err != nil {
+		log.Println("Provided Topology Watch Interval was not a valid Duration, like 10s or 1m. Falling back to 10s")
+		interval, _ = time.ParseDuration("10s")
+	}
+
+	return interval
+}
+
+func getDynamicQueueGracePeriod() time.Duration {
+	gracePeriod, err := time.ParseDuration(readFromEnv(envDynamicGracePeriod, "0s"))
+	if err != nil || gracePeriod < 0 {
+		log.Println("Provided Dynamic Bindings Delete After was not a valid Duration, like 10m or 1h. Will not delete queues")
+		gracePeriod = 0
+	}
+
+	return gracePeriod
+}
+
+func getReconnectPolicy() internal.ReconnectPolicy {
+	maxAttempts, err := strconv.Atoi(readFromEnv(envReconnectMaxAttempts, "0"))
+	if err != nil || maxAttempts < 0 {
+		log.Println("Provided Reconnect Max Attempts was not a valid positive number. Falling back to retrying infinitely")
+		maxAttempts = 0
+	}
+
+	baseDelay, err := time.ParseDuration(readFromEnv(envReconnectBaseDelay, "1s"))
+	if err != nil || baseDelay <= 0 {
+		log.Println("Provided Reconnect Base Delay was not a valid Duration, like 1s or 500ms. Falling back to 1s")
+		baseDelay = time.Second
+	}
+
+	maxDelay, err := time.ParseDuration(readFromEnv(envReconnectMaxDelay, "30s"))
+	if err != nil || maxDelay < baseDelay {
+		log.Println("Provided Reconnect Max Delay was not a valid Duration of at least the base delay, like 30s or 1m. Falling back to 30s")
+		maxDelay = 30 * time.Second
+		if maxDelay < baseDelay {
+			maxDelay = baseDelay
+		}
+	}
+
+	jitter, err := strconv.ParseFloat(readFromEnv(envReconnectJitter, "0.2"), 64)
+	if err != nil || jitter < 0 || jitter > 1 {
+		log.Println("Provided Reconnect Jitter was not a valid fraction between 0 and 1. Falling back to 0.2")
+		jitter = 0.2
+	}
+
+	return internal.ReconnectPolicy{MaxAttempts: maxAttempts, BaseDelay: baseDelay, MaxDelay: maxDelay, Jitter: jitter}
+}
+
+func getRetryPolicy() internal.RetryPolicy {
+	maxAttempts, err := strconv.Atoi(readFromEnv(envRetryMaxAttempts, "1"))
+	if err != nil || maxAttempts < 1 {
+		log.Println("Provided Invoke Retry Max Attempts was not a valid positive number. Falling back to 1, which disables retrying")
+		maxAttempts = 1
+	}
+
+	baseDelay, err := time.ParseDuration(readFromEnv(envRetryBaseDelay, "200ms"))
+	if err != nil || baseDelay <= 0 {
+		log.Println("Provided Invoke Retry Base Delay was not a valid Duration, like 200ms or 1s. Falling back to 200ms")
+		baseDelay = 200 * time.Millisecond
+	}
+
+	maxDelay, err := time.ParseDuration(readFromEnv(envRetryMaxDelay, "5s"))
+	if err != nil || maxDelay < baseDelay {
+		log.Println("Provided Invoke Retry Max Delay was not a valid Duration of at least the base delay, like 5s or 1m. Falling back to 5s")
+		maxDelay = 5 * time.Second
+		if maxDelay < baseDelay {
+			maxDelay = baseDelay
+		}
+	}
+
+	jitter, err := strconv.ParseFloat(readFromEnv(envRetryJitter, "0.2"), 64)
+	if err != nil || jitter < 0 || jitter > 1 {
+		log.Println("Provided Invoke Retry Jitter was not a valid fraction between 0 and 1. Falling back to 0.2")
+		jitter = 0.2
+	}
+
+	return internal.RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+		Jitter:      jitter,
+		Budget:      getDuration(envRetryBudget, "30s"),
+		Classes:     getRetryClasses(),
+	}
+}
+
+func getRetryClasses() []internal.ErrorClass {
+	var classes []internal.ErrorClass
+
+	for _, raw := range strings.Split(readFromEnv(envRetryClasses, defaultRetryClasses), ",") {
+		class := internal.ErrorClass(strings.ToLower(strings.TrimSpace(raw)))
+		if len(class) == 0 {
+			continue
+		}
+
+		if !class.Known() {
+			log.Printf("Provided Invoke Retry Class %s is not known. Will ignore it", raw)
+			continue
+		}
+		classes = append(classes, class)
+	}
+
+	return classes
+}
+
+// ConnectionOptions returns the options for connecting to Rabbit MQ described by the config
+func (c *Controller) ConnectionOptions() rabbitmq.ConnectionOptions {
+	return rabbitmq.ConnectionOptions{
+		TLSConfig: c.TLSConfig,
+		Policy:    c.Reconnect,
+		Strategy:  c.NodeStrategy,
+
+		Heartbeat:   c.Heartbeat,
+		ChannelMax:  c.ChannelMax,
+		FrameSize:   c.FrameSize,
+		DialTimeout: c.DialTimeout,
+		Locale:      c.Locale,
+		Properties:  rabbitmq.ClientProperties(c.ConnectionName, c.InstanceID),
+		SASL:        rabbitmq.Authentication(c.AuthMechanism),
+	}
+}
+
+func defaultConnectionName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "rabbitmq-connector"
+	}
+	return "rabbitmq-connector@" + hostname
+}
+
+func generateInstanceID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+func getDuration(env string, fallback string) time.Duration {
+	value, err := time.ParseDuration(readFromEnv(env, fallback))
+	if err != nil || value < 0 {
+		log.Printf("Provided %s was not a valid Duration, like 10s or 1m. Falling back to %s", env, fallback)
+		value, _ = time.ParseDuration(fallback)
+	}
+
+	return value
+}
+
+func getNonNegativeInt(env string, fallback string) int {
+	value, err := strconv.Atoi(readFromEnv(env, fallback))
+	if err != nil || value < 0 {
+		log.Printf("Provided %s was not a valid positive number. Falling back to %s", env, fallback)
+		value, _ = strconv.Atoi(fallback)
+	}
+
+	return value
+}
+
 // Helper Functions
 func readFromEnv(env string, fallback string) string {
 	if val, exists := os.LookupEnv(env); exists {
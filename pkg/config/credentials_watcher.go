@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// RabbitURLs are the connection urls rebuilt from rotated credentials, together with their sanitized version for logging
+type RabbitURLs struct {
+	URLs         []string
+	SanitizedURL string
+}
+
+// CredentialWatcher observes the files referenced by RMQ_URLS_FILE, RMQ_URL_FILE, RMQ_USER_FILE and RMQ_PASS_FILE and
+// publishes the rebuilt connection urls every time the credentials change
+type CredentialWatcher struct {
+	*fileWatcher
+	isTLS bool
+	urls  []string
+}
+
+// NewCredentialWatcher creates a new watcher for the credentials configured via env. Their current content and the urls
+// of the config are used as the baseline, so only rotations afterwards are published. A watch interval of 0 disables
+// polling, in which case the credentials are only re-read when Trigger is called. Without any credentials read from
+// files there is nothing to watch, hence polling is disabled as well.
+func NewCredentialWatcher(fs afero.Fs, conf *Controller) *CredentialWatcher {
+	files := secretFiles(rabbitSecrets)
+
+	interval := conf.CredentialsWatchInterval
+	if len(files) == 0 {
+		interval = 0
+	}
+
+	return &CredentialWatcher{
+		fileWatcher: newFileWatcher(fs, "Rabbit MQ credentials", interval, staticPaths(files)),
+		isTLS:       conf.TLSConfig != nil,
+		urls:        conf.RabbitConnectionURLs,
+	}
+}
+
+// Watch starts observing the credentials until the context is done. Rebuilt urls are published on the returned channel,
+// which is closed once watching stopped. Credentials that fail to load, e.g. because a file is only partially written,
+// are retried with the next check.
+func (w *CredentialWatcher) Watch(ctx context.Context) <-chan RabbitURLs {
+	updates := make(chan RabbitURLs)
+
+	go func() {
+		defer close(updates)
+
+		w.watch(ctx, func() bool {
+			urls, sanitizedURLs, err := getRabbitMQConnectionURLs(w.fs, w.isTLS)
+			if err != nil {
+				log.Printf("Received %s while loading rotated Rabbit MQ credentials, will keep the current ones", err)
+				return false
+			}
+
+			if reflect.DeepEqual(urls, w.urls) {
+				return true
+			}
+
+			log.Println("Detected rotated Rabbit MQ credentials")
+			w.urls = urls
+			select {
+			case updates <- RabbitURLs{URLs: urls, SanitizedURL: strings.Join(sanitizedURLs, ", ")}:
+			case <-ctx.Done():
+			}
+			return true
+		})
+	}()
+
+	return updates
+}
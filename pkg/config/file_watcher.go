@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// fileWatcher polls a list of files and calls back once their content changed, it is the base of the watchers
+// for topology, certificates and credentials
+type fileWatcher struct {
+	fs       afero.Fs
+	name     string
+	paths    func() ([]string, error)
+	interval time.Duration
+
+	current map[string][]byte
+	trigger chan struct{}
+}
+
+// newFileWatcher creates a new watcher for the files returned by paths, which are resolved again on every check, so
+// files can be added or removed. Their current content is used as the baseline, so only changes afterwards are
+// reported. A watch interval of 0 disables polling, in which case the files are only checked when Trigger is called.
+func newFileWatcher(fs afero.Fs, name string, interval time.Duration, paths func() ([]string, error)) *fileWatcher {
+	w := &fileWatcher{
+		fs:       fs,
+		name:     name,
+		paths:    paths,
+		interval: interval,
+
+		trigger: make(chan struct{}, 1),
+	}
+	w.current, _ = w.snapshot()
+
+	return w
+}
+
+// Trigger requests an immediate check of the files, e.g. after receiving SIGHUP
+func (w *fileWatcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+		// A check is already pending
+	}
+}
+
+// watch checks the files until the context is done and calls onChange once their content changed. If onChange
+// reports false, e.g. because the files were only partially written, the change is reported again with the next check.
+func (w *fileWatcher) watch(ctx context.Context, onChange func() bool) {
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-w.trigger:
+			log.Printf("Re-reading %s", w.name)
+		case <-ctx.Done():
+			log.Printf("Received done via context will stop watching %s", w.name)
+			return
+		}
+
+		snapshot, err := w.snapshot()
+		if err != nil {
+			log.Printf("Received %s while reading %s, will keep the current ones", err, w.name)
+			continue
+		}
+
+		if w.changed(snapshot) && onChange() {
+			w.current = snapshot
+		}
+	}
+}
+
+func (w *fileWatcher) snapshot() (map[string][]byte, error) {
+	paths, err := w.paths()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string][]byte)
+	for _, path := range paths {
+		content, err := afero.ReadFile(w.fs, path)
+		if err != nil {
+			return nil, err
+		}
+		snapshot[path] = content
+	}
+
+	return snapshot, nil
+}
+
+func (w *fileWatcher) changed(snapshot map[string][]byte) bool {
+	if len(snapshot) != len(w.current) {
+		return true
+	}
+
+	for path, content := range snapshot {
+		if previous, exists := w.current[path]; !exists || !bytes.Equal(previous, content) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticPaths returns a resolver for a fixed list of files
+func staticPaths(paths []string) func() ([]string, error) {
+	return func() ([]string, error) {
+		return paths, nil
+	}
+}
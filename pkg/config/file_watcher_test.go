@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileWatcher_Watch(t *testing.T) {
+	start := func(fs afero.Fs, paths func() ([]string, error), onChange func() bool) (*fileWatcher, context.CancelFunc) {
+		watcher := newFileWatcher(fs, "files", 0, paths)
+		ctx, cancel := context.WithCancel(context.Background())
+		go watcher.watch(ctx, onChange)
+		return watcher, cancel
+	}
+
+	awaitChange := func(changes <-chan struct{}) bool {
+		select {
+		case <-changes:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	t.Run("Should call back once content of a file changed", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "a.yaml", []byte("a"), 0644)
+
+		changes := make(chan struct{}, 1)
+		watcher, cancel := start(fs, staticPaths([]string{"a.yaml"}), func() bool {
+			changes <- struct{}{}
+			return true
+		})
+		defer cancel()
+
+		watcher.Trigger()
+		assert.False(t, awaitChange(changes), "Should not call back for unchanged files")
+
+		_ = afero.WriteFile(fs, "a.yaml", []byte("b"), 0644)
+		watcher.Trigger()
+		assert.True(t, awaitChange(changes), "Should call back for changed files")
+
+		watcher.Trigger()
+		assert.False(t, awaitChange(changes), "Should not call back for an accepted change again")
+	})
+
+	t.Run("Should call back once files got added", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "dir/a.yaml", []byte("a"), 0644)
+
+		changes := make(chan struct{}, 1)
+		watcher, cancel := start(fs, func() ([]string, error) { return afero.Glob(fs, "dir/*.yaml") }, func() bool {
+			changes <- struct{}{}
+			return true
+		})
+		defer cancel()
+
+		_ = afero.WriteFile(fs, "dir/b.yaml", []byte("b"), 0644)
+		watcher.Trigger()
+		assert.True(t, awaitChange(changes))
+	})
+
+	t.Run("Should report rejected changes again with the next check", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "a.yaml", []byte("a"), 0644)
+
+		changes := make(chan struct{}, 1)
+		watcher, cancel := start(fs, staticPaths([]string{"a.yaml"}), func() bool {
+			changes <- struct{}{}
+			return false
+		})
+		defer cancel()
+
+		_ = afero.WriteFile(fs, "a.yaml", []byte("b"), 0644)
+		watcher.Trigger()
+		assert.True(t, awaitChange(changes))
+
+		watcher.Trigger()
+		assert.True(t, awaitChange(changes))
+	})
+
+	t.Run("Should not call back if files could not be read", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "a.yaml", []byte("a"), 0644)
+
+		changes := make(chan struct{}, 1)
+		watcher, cancel := start(fs, staticPaths([]string{"a.yaml"}), func() bool {
+			changes <- struct{}{}
+			return true
+		})
+		defer cancel()
+
+		_ = fs.Remove("a.yaml")
+		watcher.Trigger()
+		assert.False(t, awaitChange(changes), "Should keep current files if one is missing")
+	})
+
+	t.Run("Should poll in the configured interval", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "a.yaml", []byte("a"), 0644)
+
+		changes := make(chan struct{}, 1)
+		watcher := newFileWatcher(fs, "files", 10*time.Millisecond, staticPaths([]string{"a.yaml"}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go watcher.watch(ctx, func() bool {
+			changes <- struct{}{}
+			return true
+		})
+
+		_ = afero.WriteFile(fs, "a.yaml", []byte("b"), 0644)
+		assert.True(t, awaitChange(changes))
+	})
+}
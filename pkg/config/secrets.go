@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// fileSuffix marks envs that reference a file containing the value of a secret, e.g. RMQ_PASS_FILE for RMQ_PASS
+const fileSuffix = "_FILE"
+
+// rabbitSecrets are the envs containing credentials for Rabbit MQ, which are watched for rotations if read from files
+var rabbitSecrets = []string{envRabbitURLs, envRabbitURL, envRabbitUser, envRabbitPass}
+
+// readSecret reads the secret either from the env or from the file referenced by the env with the suffix _FILE, so
+// secrets can be mounted as files instead of being exposed as plain envs. Surrounding whitespace of files is removed.
+func readSecret(fs afero.Fs, env string, fallback string) (string, error) {
+	path := readFromEnv(env+fileSuffix, "")
+	if len(path) == 0 {
+		return readFromEnv(env, fallback), nil
+	}
+
+	if _, exists := os.LookupEnv(env); exists {
+		return "", fmt.Errorf("Provided %s and %s, only one of them can be used", env, env+fileSuffix)
+	}
+
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return "", fmt.Errorf("Provided %s at %s does not exist or is not accessible %s", env+fileSuffix, path, err)
+	}
+
+	value := strings.TrimSpace(string(content))
+	if len(value) == 0 {
+		return "", fmt.Errorf("Provided %s at %s is empty", env+fileSuffix, path)
+	}
+
+	return value, nil
+}
+
+// secretFiles returns the files referenced by the _FILE variants of the envs
+func secretFiles(envs []string) []string {
+	var files []string
+
+	for _, env := range envs {
+		if path := readFromEnv(env+fileSuffix, ""); len(path) > 0 {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
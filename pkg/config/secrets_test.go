@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSecret(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "secrets/pass", []byte("s3cr3t\n"), 0600)
+	_ = afero.WriteFile(fs, "secrets/empty", []byte("\n"), 0600)
+
+	t.Run("Should read secret from env", func(t *testing.T) {
+		os.Setenv("RMQ_PASS", "plain")
+		defer os.Unsetenv("RMQ_PASS")
+
+		value, err := readSecret(fs, "RMQ_PASS", "")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "plain", value)
+	})
+
+	t.Run("Should read secret from file without surrounding whitespace", func(t *testing.T) {
+		os.Setenv("RMQ_PASS_FILE", "secrets/pass")
+		defer os.Unsetenv("RMQ_PASS_FILE")
+
+		value, err := readSecret(fs, "RMQ_PASS", "")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "s3cr3t", value)
+	})
+
+	t.Run("Should fall back if neither is provided", func(t *testing.T) {
+		value, err := readSecret(fs, "RMQ_PASS", "fallback")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "fallback", value)
+	})
+
+	t.Run("Should reject env and file being provided together", func(t *testing.T) {
+		os.Setenv("RMQ_PASS", "plain")
+		os.Setenv("RMQ_PASS_FILE", "secrets/pass")
+		defer os.Unsetenv("RMQ_PASS")
+		defer os.Unsetenv("RMQ_PASS_FILE")
+
+		_, err := readSecret(fs, "RMQ_PASS", "")
+
+		assert.EqualError(t, err, "Provided RMQ_PASS and RMQ_PASS_FILE, only one of them can be used")
+	})
+
+	t.Run("Should reject missing or empty files", func(t *testing.T) {
+		os.Setenv("RMQ_PASS_FILE", "secrets/missing")
+		_, err := readSecret(fs, "RMQ_PASS", "")
+		assert.Error(t, err, "should throw")
+
+		os.Setenv("RMQ_PASS_FILE", "secrets/empty")
+		defer os.Unsetenv("RMQ_PASS_FILE")
+		_, err = readSecret(fs, "RMQ_PASS", "")
+		assert.EqualError(t, err, "Provided RMQ_PASS_FILE at secrets/empty is empty")
+	})
+}
@@ -0,0 +1,438 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	internal "github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// envConfigFile names the config file, which can also be passed via the flag config
+const envConfigFile = "CONFIG_FILE"
+
+// Origins of the effective value of a setting
+const (
+	OriginDefault = "default"
+	OriginFile    = "file"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+// envRemovedTopics was replaced by the topology, it is still listed in older deployments
+const envRemovedTopics = "RMQ_TOPICS"
+
+type settingKind int
+
+const (
+	kindText settingKind = iota
+	kindBool
+	kindDuration
+	kindCount
+	kindPort
+	kindFraction
+	kindHTTP
+	kindAMQP
+	kindClasses
+)
+
+// setting describes an env the connector reads, it is used for the config file and flags as well as for validating
+// and printing the configuration. Secrets are redacted when printed and can also be read from files, see readSecret.
+type setting struct {
+	env      string
+	fallback string
+	kind     settingKind
+	values   []string
+	secret   bool
+}
+
+// key returns the name of the setting within the config file and as flag, which is the env in lower case using dashes
+func (s setting) key() string {
+	return strings.ReplaceAll(strings.ToLower(s.env), "_", "-")
+}
+
+var settings = withSecretFiles([]setting{
+	{env: "basic_auth", fallback: "false", kind: kindBool, values: []string{"true", "false", "1", "0"}},
+	{env: envSecretMountPath},
+	{env: envFaaSGwURL, fallback: "http://gateway:8080", kind: kindHTTP},
+	{env: envGatewayAuth, values: []string{GatewayAuthNone, GatewayAuthBasic, GatewayAuthBearer, GatewayAuthClientCredentials}},
+	{env: envGatewayAuthWatch, fallback: "30s", kind: kindDuration},
+	{env: envGatewayAuthPause, fallback: "10s", kind: kindDuration},
+	{env: envGatewayTokenFile},
+	{env: envOAuthTokenURL, kind: kindHTTP},
+	{env: envOAuthClientID, secret: true},
+	{env: envOAuthClientSecret, secret: true},
+	{env: envOAuthScopes},
+	{env: envOAuthAudience},
+	{env: envOAuthCACert},
+	{env: envOAuthCert},
+	{env: envOAuthKey},
+	{env: envOAuthMinVersion, fallback: "1.2", values: tlsVersionNames()},
+	{env: envGatewayCACert},
+	{env: envGatewayCert},
+	{env: envGatewayKey},
+	{env: envGatewayServerName},
+	{env: envGatewayMinVersion, fallback: "1.2", values: tlsVersionNames()},
+	{env: envSkipVerify, fallback: "false", kind: kindBool},
+	{env: envMaxClientsPerHost, fallback: "256", kind: kindCount},
+	{env: envRequestTimeout, fallback: "30s", kind: kindDuration},
+	{env: envRetryMaxAttempts, fallback: "1", kind: kindCount},
+	{env: envRetryBaseDelay, fallback: "200ms", kind: kindDuration},
+	{env: envRetryMaxDelay, fallback: "5s", kind: kindDuration},
+	{env: envRetryJitter, fallback: "0.2", kind: kindFraction},
+	{env: envRetryBudget, fallback: "30s", kind: kindDuration},
+	{env: envRetryClasses, fallback: defaultRetryClasses, kind: kindClasses},
+	{env: envRefreshTime, fallback: "30s", kind: kindDuration},
+
+	{env: envUseTLS, fallback: "false", kind: kindBool},
+	{env: envPathToCACert},
+	{env: envPathToServerCert},
+	{env: envPathToServerKey},
+	{env: envTLSServerName},
+	{env: envTLSMinVersion, fallback: "1.2", values: tlsVersionNames()},
+	{env: envTLSCipherSuites},
+	{env: envTLSSkipVerify, fallback: "false", kind: kindBool},
+	{env: envTLSWatchInterval, fallback: "1m", kind: kindDuration},
+	{env: envTLSReconnectBeforeExpiry, fallback: "0s", kind: kindDuration},
+
+	{env: envRabbitHost, fallback: "localhost"},
+	{env: envRabbitPort, fallback: "5672", kind: kindPort},
+	{env: envRabbitVHost},
+	{env: envRabbitUser, secret: true},
+	{env: envRabbitPass, secret: true},
+	{env: envRabbitURL, kind: kindAMQP, secret: true},
+	{env: envRabbitURLs, kind: kindAMQP, secret: true},
+	{env: envRabbitAuth, fallback: "PLAIN", values: []string{"PLAIN", "EXTERNAL"}},
+	{env: envCredentialsWatch, fallback: "1m", kind: kindDuration},
+	{env: envNodeStrategy, fallback: "round-robin", values: []string{"round-robin", "shuffle"}},
+	{env: envManagementURL, kind: kindHTTP},
+	{env: envManagementUser, secret: true},
+	{env: envManagementPass, secret: true},
+	{env: envManagementCACert},
+	{env: envManagementCert},
+	{env: envManagementKey},
+	{env: envManagementMinVersion, fallback: "1.2", values: tlsVersionNames()},
+	{env: envHeartbeat, fallback: "10s", kind: kindDuration},
+	{env: envChannelMax, fallback: "0", kind: kindCount},
+	{env: envFrameSize, fallback: "0", kind: kindCount},
+	{env: envDialTimeout, fallback: "30s", kind: kindDuration},
+	{env: envLocale, fallback: "en_US"},
+	{env: envConnectionName},
+	{env: envInstanceID},
+
+	{env: envPathToTopology},
+	{env: envTopologyOverlay},
+	{env: envDefinitionsVHost},
+	{env: envDefinitionsPrefix},
+	{env: envStrictTopology, fallback: "false", kind: kindBool},
+	{env: envTopologyWatchPeriod, fallback: "10s", kind: kindDuration},
+
+	{env: envDynamicBindings, fallback: "false", kind: kindBool},
+	{env: envDynamicExchange, fallback: "amq.topic"},
+	{env: envDynamicGracePeriod, fallback: "0s", kind: kindDuration},
+
+	{env: envReconnectMaxAttempts, fallback: "0", kind: kindCount},
+	{env: envReconnectBaseDelay, fallback: "1s", kind: kindDuration},
+	{env: envReconnectMaxDelay, fallback: "30s", kind: kindDuration},
+	{env: envReconnectJitter, fallback: "0.2", kind: kindFraction},
+	{env: envHealthAddr},
+})
+
+// withSecretFiles adds the _FILE variant of every secret right after it
+func withSecretFiles(list []setting) []setting {
+	var out []setting
+
+	for _, s := range list {
+		out = append(out, s)
+		if s.secret {
+			out = append(out, setting{env: s.env + fileSuffix})
+		}
+	}
+
+	return out
+}
+
+func tlsVersionNames() []string {
+	var names []string
+	for name := range tlsVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Origins reports for every setting where its effective value came from
+type Origins map[string]string
+
+// Load reads the config file and parses the flags, both use the keys of the settings like rmq-host for RMQ_HOST. Their
+// values are exposed as envs, so they are picked up like any other env with the precedence flags > envs > config file
+// > defaults. The config file is either passed via the flag config or CONFIG_FILE. Unknown keys or flags are rejected.
+func Load(fs afero.Fs, args []string, output io.Writer) (Origins, error) {
+	flags := flag.NewFlagSet("rmq-connector", flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	configFile := flags.String("config", readFromEnv(envConfigFile, ""), "path to the yaml config file, defaults to "+envConfigFile)
+	values := make(map[string]*string)
+	for _, s := range settings {
+		values[s.env] = flags.String(s.key(), "", "overrides "+s.env)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("Provided argument %s is not a flag", flags.Arg(0))
+	}
+
+	origins := make(Origins)
+	for _, s := range settings {
+		if _, exists := os.LookupEnv(s.env); exists {
+			origins[s.env] = OriginEnv
+		} else {
+			origins[s.env] = OriginDefault
+		}
+	}
+
+	if len(*configFile) > 0 {
+		fromFile, err := readConfigFile(fs, *configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for env, value := range fromFile {
+			if origins[env] == OriginEnv {
+				continue
+			}
+
+			_ = os.Setenv(env, value)
+			origins[env] = OriginFile
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.key() == f.Name {
+				_ = os.Setenv(s.env, *values[s.env])
+				origins[s.env] = OriginFlag
+			}
+		}
+	})
+
+	return origins, nil
+}
+
+// readConfigFile reads the flat yaml map of setting keys to values, reporting every unknown key or invalid value with its line
+func readConfigFile(fs afero.Fs, path string) (map[string]string, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("Provided config file %s does not exist or is not accessible %s", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Provided config file %s is not valid yaml: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	values := make(map[string]string)
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return values, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("Provided config file %s has to be a map of settings", path)
+	}
+
+	keys := make(map[string]string)
+	for _, s := range settings {
+		keys[s.key()] = s.env
+	}
+
+	var problems []error
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		env, known := keys[key.Value]
+		switch {
+		case !known:
+			problems = append(problems, fmt.Errorf("%s:%d: unknown setting %s", path, key.Line, key.Value))
+		case value.Kind != yaml.ScalarNode:
+			problems = append(problems, fmt.Errorf("%s:%d: setting %s has to be a single value", path, value.Line, key.Value))
+		default:
+			values[env] = value.Value
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	return values, nil
+}
+
+// validateSettings checks the provided value of every setting and reports all invalid ones at once, instead of
+// silently falling back to their defaults. Empty values are treated like settings that are not provided.
+func validateSettings() error {
+	var problems []error
+
+	if _, exists := os.LookupEnv(envRemovedTopics); exists {
+		log.Printf("%s is no longer supported and will be ignored, topics are configured via %s", envRemovedTopics, envPathToTopology)
+	}
+
+	for _, s := range settings {
+		value := readFromEnv(s.env, "")
+		if len(value) == 0 {
+			continue
+		}
+
+		if err := s.validate(value); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	baseDelay, baseErr := time.ParseDuration(readFromEnv(envReconnectBaseDelay, "1s"))
+	maxDelay, maxErr := time.ParseDuration(readFromEnv(envReconnectMaxDelay, "30s"))
+	switch {
+	case baseErr == nil && baseDelay == 0:
+		problems = append(problems, fmt.Errorf("Provided %s has to be longer than 0s", envReconnectBaseDelay))
+	case baseErr == nil && maxErr == nil && maxDelay < baseDelay:
+		problems = append(problems, fmt.Errorf("Provided %s %s is shorter than %s %s", envReconnectMaxDelay, maxDelay, envReconnectBaseDelay, baseDelay))
+	}
+
+	retryBase, baseErr := time.ParseDuration(readFromEnv(envRetryBaseDelay, "200ms"))
+	retryMax, maxErr := time.ParseDuration(readFromEnv(envRetryMaxDelay, "5s"))
+	switch {
+	case baseErr == nil && retryBase == 0:
+		problems = append(problems, fmt.Errorf("Provided %s has to be longer than 0s", envRetryBaseDelay))
+	case baseErr == nil && maxErr == nil && retryMax < retryBase:
+		problems = append(problems, fmt.Errorf("Provided %s %s is shorter than %s %s", envRetryMaxDelay, retryMax, envRetryBaseDelay, retryBase))
+	}
+
+	if attempts, err := strconv.Atoi(readFromEnv(envRetryMaxAttempts, "1")); err == nil && attempts == 0 {
+		problems = append(problems, fmt.Errorf("Provided %s has to be at least 1", envRetryMaxAttempts))
+	}
+
+	return errors.Join(problems...)
+}
+
+func (s setting) validate(value string) error {
+	if len(s.values) > 0 {
+		for _, allowed := range s.values {
+			if strings.EqualFold(value, allowed) {
+				return nil
+			}
+		}
+		if len(s.values) == 2 {
+			return fmt.Errorf("Provided %s %s is neither %s nor %s", s.env, value, s.values[0], s.values[1])
+		}
+		last := len(s.values) - 1
+		return fmt.Errorf("Provided %s %s is not one of %s or %s", s.env, value, strings.Join(s.values[:last], ", "), s.values[last])
+	}
+
+	switch s.kind {
+	case kindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("Provided %s %s is neither true nor false", s.env, value)
+		}
+	case kindDuration:
+		if parsed, err := time.ParseDuration(value); err != nil || parsed < 0 {
+			return fmt.Errorf("Provided %s %s is not a valid Duration, like 10s or 1m", s.env, value)
+		}
+	case kindCount:
+		if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+			return fmt.Errorf("Provided %s %s is not a valid positive number", s.env, value)
+		}
+	case kindPort:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Provided %s %s is not a valid port", s.env, value)
+		}
+		if parsed <= 0 || parsed > 65535 {
+			return fmt.Errorf("Provided %s %s is outside of the allowed port range", s.env, value)
+		}
+	case kindFraction:
+		if parsed, err := strconv.ParseFloat(value, 64); err != nil || parsed < 0 || parsed > 1 {
+			return fmt.Errorf("Provided %s %s is not a number between 0 and 1", s.env, value)
+		}
+	case kindHTTP:
+		if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+			return fmt.Errorf("Provided %s %s does not include the protocol http / https", s.env, redact(value))
+		}
+	case kindAMQP:
+		for _, rawURL := range strings.Split(value, ",") {
+			if _, _, err := parseRabbitMQURL(rawURL); err != nil {
+				return fmt.Errorf("Provided %s contains an invalid url: %s", s.env, err)
+			}
+		}
+	case kindClasses:
+		for _, raw := range strings.Split(value, ",") {
+			class := internal.ErrorClass(strings.ToLower(strings.TrimSpace(raw)))
+			if class == internal.ClassNone || !class.Known() {
+				return fmt.Errorf("Provided %s contains %s, which is not one of unauthorized, not-found, client-error, server-error, timeout, canceled or transport", s.env, raw)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PrintSettings writes the effective value of every setting as config file, annotated with its origin. Secrets are redacted,
+// only the host of urls containing credentials is kept.
+func PrintSettings(w io.Writer, origins Origins) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, s := range settings {
+		value := readFromEnv(s.env, s.fallback)
+		if len(value) > 0 && s.secret {
+			value = s.redact(value)
+		}
+
+		origin := origins[s.env]
+		if len(origin) == 0 {
+			origin = OriginDefault
+		}
+
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: s.key()},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, LineComment: origin},
+		)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func (s setting) redact(value string) string {
+	if s.kind != kindAMQP {
+		return "<redacted>"
+	}
+
+	var redacted []string
+	for _, rawURL := range strings.Split(value, ",") {
+		redacted = append(redacted, redact(strings.TrimSpace(rawURL)))
+	}
+
+	return strings.Join(redacted, ",")
+}
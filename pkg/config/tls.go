@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// expiryWarningPeriod is the remaining validity below which certificates are reported as expiring soon
+const expiryWarningPeriod = 30 * 24 * time.Hour
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsSettings names the env variables a TLS config is loaded from, settings without a name are not configurable
+type tlsSettings struct {
+	peer         string
+	prefix       string
+	caCert       string
+	cert         string
+	key          string
+	serverName   string
+	minVersion   string
+	cipherSuites string
+	skipVerify   string
+}
+
+var rabbitTLS = tlsSettings{
+	peer:         "Rabbit MQ",
+	caCert:       envPathToCACert,
+	cert:         envPathToServerCert,
+	key:          envPathToServerKey,
+	serverName:   envTLSServerName,
+	minVersion:   envTLSMinVersion,
+	cipherSuites: envTLSCipherSuites,
+	skipVerify:   envTLSSkipVerify,
+}
+
+var gatewayTLS = tlsSettings{
+	peer:       "the OpenFaaS gateway",
+	prefix:     "Gateway ",
+	caCert:     envGatewayCACert,
+	cert:       envGatewayCert,
+	key:        envGatewayKey,
+	serverName: envGatewayServerName,
+	minVersion: envGatewayMinVersion,
+	skipVerify: envSkipVerify,
+}
+
+var oauthTLS = tlsSettings{
+	peer:       "the OAuth token endpoint",
+	prefix:     "Token ",
+	caCert:     envOAuthCACert,
+	cert:       envOAuthCert,
+	key:        envOAuthKey,
+	minVersion: envOAuthMinVersion,
+}
+
+var managementTLS = tlsSettings{
+	peer:       "the Rabbit MQ management API",
+	prefix:     "Management ",
+	caCert:     envManagementCACert,
+	cert:       envManagementCert,
+	key:        envManagementKey,
+	minVersion: envManagementMinVersion,
+}
+
+// generateTlsConfig builds the TLS config for the connection to Rabbit MQ. The CA is optional and defaults to the
+// system roots, the same goes for the client certificate, which is only needed if RabbitMQ verifies its peers.
+func generateTlsConfig(fs afero.Fs) (*tls.Config, error) {
+	return loadTLSConfig(fs, rabbitTLS)
+}
+
+// generateGatewayTlsConfig builds the TLS config for the connection to the OpenFaaS gateway, like for Rabbit MQ
+// CA and client certificate are optional.
+func generateGatewayTlsConfig(fs afero.Fs) (*tls.Config, error) {
+	return loadTLSConfig(fs, gatewayTLS)
+}
+
+// generateOAuthTlsConfig builds the TLS config for the token endpoint, which is independent of the gateway. Without
+// further settings it trusts the CAs of the system and presents no client certificate.
+func generateOAuthTlsConfig(fs afero.Fs) (*tls.Config, error) {
+	return loadTLSConfig(fs, oauthTLS)
+}
+
+// generateManagementTlsConfig builds the TLS config for the management API, which usually is served with another
+// certificate than the AMQP listener. Without further settings it trusts the CAs of the system.
+func generateManagementTlsConfig(fs afero.Fs) (*tls.Config, error) {
+	return loadTLSConfig(fs, managementTLS)
+}
+
+func loadTLSConfig(fs afero.Fs, settings tlsSettings) (*tls.Config, error) {
+	caCertPath := readSetting(settings.caCert, "")
+	if len(caCertPath) > 0 {
+		if exists, err := afero.Exists(fs, caCertPath); !exists {
+			return nil, fmt.Errorf("%sCa Cert at %s does not exist or is not accessible %s", settings.prefix, caCertPath, err)
+		}
+	}
+
+	serverCertPath := readSetting(settings.cert, "")
+	serverKeyPath := readSetting(settings.key, "")
+
+	if (len(serverCertPath) == 0) != (len(serverKeyPath) == 0) {
+		return nil, fmt.Errorf("%s and %s have to be provided together", settings.cert, settings.key)
+	}
+
+	if len(serverCertPath) > 0 {
+		if exists, err := afero.Exists(fs, serverCertPath); !exists {
+			return nil, fmt.Errorf("%sServer Cert at %s does not exist or is not accessible %s", settings.prefix, serverCertPath, err)
+		}
+
+		if exists, err := afero.Exists(fs, serverKeyPath); !exists {
+			return nil, fmt.Errorf("%sServer Key at %s does not exist or is not accessible %s", settings.prefix, serverKeyPath, err)
+		}
+	}
+
+	minVersion, err := getTLSMinVersion(settings.minVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherSuites, err := getTLSCipherSuites(settings.cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	skipVerify, err := strconv.ParseBool(readSetting(settings.skipVerify, "false"))
+	if err != nil {
+		skipVerify = false
+	}
+
+	// At this point we know every configured file is present and accessible
+	cfg := &tls.Config{
+		ServerName:         readSetting(settings.serverName, ""),
+		MinVersion:         minVersion,
+		CipherSuites:       cipherSuites,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if skipVerify {
+		log.Printf("%s is enabled, the certificate of %s will not be verified", settings.skipVerify, settings.peer)
+	}
+
+	if len(caCertPath) > 0 {
+		ca, err := afero.ReadFile(fs, caCertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%sCa Cert at %s does not contain a PEM encoded certificate", settings.prefix, caCertPath)
+		}
+
+		for _, cert := range parseCertificates(ca) {
+			reportCertificateExpiry(settings.prefix+"Ca Cert", cert, time.Now())
+		}
+	}
+
+	if len(serverCertPath) > 0 {
+		cert, err := afero.ReadFile(fs, serverCertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := afero.ReadFile(fs, serverKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = append(cfg.Certificates, pair)
+
+		for _, cert := range parseCertificates(cert) {
+			reportCertificateExpiry(settings.prefix+"Client Cert", cert, time.Now())
+		}
+	}
+
+	return cfg, nil
+}
+
+// readSetting reads the env variable of a setting, falling back if the setting is not configurable
+func readSetting(env string, fallback string) string {
+	if len(env) == 0 {
+		return fallback
+	}
+
+	return readFromEnv(env, fallback)
+}
+
+func getTLSMinVersion(env string) (uint16, error) {
+	raw := readSetting(env, "1.2")
+
+	version, known := tlsVersions[raw]
+	if !known {
+		return 0, fmt.Errorf("Provided TLS min version %s is not one of 1.0, 1.1, 1.2 or 1.3", raw)
+	}
+
+	return version, nil
+}
+
+// getTLSCipherSuites resolves the comma separated names of the cipher suites, like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+// The suites only apply up to TLS 1.2, as TLS 1.3 suites are not configurable.
+func getTLSCipherSuites(env string) ([]uint16, error) {
+	raw := readSetting(env, "")
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+
+		id, exists := known[name]
+		if !exists {
+			return nil, fmt.Errorf("Provided cipher suite %s is not supported", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// files returns the configured paths of the CA, client cert and key
+func (settings tlsSettings) files() []string {
+	var files []string
+
+	for _, env := range []string{settings.caCert, settings.cert, settings.key} {
+		if path := readSetting(env, ""); len(path) > 0 {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
+
+// ClientCertificateExpiry returns when the first of the client certificates of the TLS config expires, reporting false
+// if the config does not present any client certificate.
+func ClientCertificateExpiry(cfg *tls.Config) (time.Time, bool) {
+	var expiry time.Time
+	found := false
+
+	if cfg == nil {
+		return expiry, false
+	}
+
+	for _, pair := range cfg.Certificates {
+		if len(pair.Certificate) == 0 {
+			continue
+		}
+
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			continue
+		}
+
+		if !found || leaf.NotAfter.Before(expiry) {
+			expiry = leaf.NotAfter
+			found = true
+		}
+	}
+
+	return expiry, found
+}
+
+// parseCertificates returns every certificate of the PEM encoded bundle, blocks that are no certificates are skipped
+func parseCertificates(bundle []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+
+	for block, rest := pem.Decode(bundle); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs
+}
+
+func reportCertificateExpiry(kind string, cert *x509.Certificate, now time.Time) {
+	log.Println(certificateExpiry(kind, cert, now))
+}
+
+// certificateExpiry describes when the certificate expires, highlighting expired certificates and those expiring soon
+func certificateExpiry(kind string, cert *x509.Certificate, now time.Time) string {
+	subject := cert.Subject.String()
+	remaining := cert.NotAfter.Sub(now)
+
+	switch {
+	case remaining <= 0:
+		return fmt.Sprintf("WARNING: %s %s expired at %s", kind, subject, cert.NotAfter.Format(time.RFC3339))
+	case remaining < expiryWarningPeriod:
+		return fmt.Sprintf("WARNING: %s %s expires soon at %s, in %d days", kind, subject, cert.NotAfter.Format(time.RFC3339), int(remaining.Hours()/24))
+	default:
+		return fmt.Sprintf("%s %s expires at %s, in %d days", kind, subject, cert.NotAfter.Format(time.RFC3339), int(remaining.Hours()/24))
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCertificates(t *testing.T) {
+	caCert, clientCert, clientKey, err := createTestCertBundle()
+	if err != nil {
+		t.Fatalf("createTestCertBundle failed with %s", err)
+	}
+
+	t.Run("Should return every certificate of the bundle", func(t *testing.T) {
+		bundle := append(append([]byte{}, caCert...), clientCert...)
+
+		assert.Len(t, parseCertificates(bundle), 2)
+	})
+
+	t.Run("Should skip blocks that are no certificates", func(t *testing.T) {
+		assert.Empty(t, parseCertificates(clientKey))
+	})
+}
+
+func TestClientCertificateExpiry(t *testing.T) {
+	_, clientCert, clientKey, err := createTestCertBundle()
+	if err != nil {
+		t.Fatalf("createTestCertBundle failed with %s", err)
+	}
+
+	t.Run("Should return expiry of the client certificate", func(t *testing.T) {
+		pair, _ := tls.X509KeyPair(clientCert, clientKey)
+
+		expiry, ok := ClientCertificateExpiry(&tls.Config{Certificates: []tls.Certificate{pair}})
+
+		assert.True(t, ok, "should find client certificate")
+		assert.Equal(t, parseCertificates(clientCert)[0].NotAfter, expiry)
+	})
+
+	t.Run("Should report missing client certificate", func(t *testing.T) {
+		_, ok := ClientCertificateExpiry(&tls.Config{})
+		assert.False(t, ok)
+
+		_, ok = ClientCertificateExpiry(nil)
+		assert.False(t, ok)
+	})
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cert := func(notAfter time.Time) *x509.Certificate {
+		return &x509.Certificate{Subject: pkix.Name{CommonName: "connector"}, NotAfter: notAfter}
+	}
+
+	t.Run("Should report remaining validity", func(t *testing.T) {
+		actual := certificateExpiry("Client Cert", cert(now.AddDate(1, 0, 0)), now)
+
+		assert.Equal(t, "Client Cert CN=connector expires at 2022-06-01T00:00:00Z, in 365 days", actual)
+	})
+
+	t.Run("Should warn about certificates expiring soon", func(t *testing.T) {
+		actual := certificateExpiry("Client Cert", cert(now.AddDate(0, 0, 7)), now)
+
+		assert.Equal(t, "WARNING: Client Cert CN=connector expires soon at 2021-06-08T00:00:00Z, in 7 days", actual)
+	})
+
+	t.Run("Should warn about expired certificates", func(t *testing.T) {
+		actual := certificateExpiry("Ca Cert", cert(now.AddDate(0, 0, -1)), now)
+
+		assert.Equal(t, "WARNING: Ca Cert CN=connector expired at 2021-05-31T00:00:00Z", actual)
+	})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+
+	"github.com/spf13/afero"
+)
+
+// CertificateWatcher observes the CA, client cert and key and publishes a rebuilt TLS config every time one of them changes
+type CertificateWatcher struct {
+	*fileWatcher
+}
+
+// NewCertificateWatcher creates a new watcher for the certificates configured via env. Their current content is used as
+// the baseline, so only rotations afterwards are published. A watch interval of 0 disables polling, in which case the
+// certificates are only re-read when Trigger is called.
+func NewCertificateWatcher(fs afero.Fs, conf *Controller) *CertificateWatcher {
+	return &CertificateWatcher{
+		fileWatcher: newFileWatcher(fs, "TLS certificates", conf.TLSWatchInterval, staticPaths(rabbitTLS.files())),
+	}
+}
+
+// Watch starts observing the certificates until the context is done. Rebuilt TLS configs are published on the returned
+// channel, which is closed once watching stopped. As cert-manager and similar tools do not replace all files at once,
+// certificates that fail to load, e.g. because the key does not match the cert yet, are retried with the next check.
+func (w *CertificateWatcher) Watch(ctx context.Context) <-chan *tls.Config {
+	updates := make(chan *tls.Config)
+
+	go func() {
+		defer close(updates)
+
+		w.watch(ctx, func() bool {
+			cfg, err := generateTlsConfig(w.fs)
+			if err != nil {
+				log.Printf("Received %s while loading rotated TLS certificates, will keep the current ones", err)
+				return false
+			}
+
+			log.Println("Detected rotated TLS certificates")
+			select {
+			case updates <- cfg:
+			case <-ctx.Done():
+			}
+			return true
+		})
+	}()
+
+	return updates
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateWatcher_Watch(t *testing.T) {
+	caCert, clientCert, clientKey, err := createTestCertBundle()
+	if err != nil {
+		t.Fatalf("createTestCertBundle failed with %s", err)
+	}
+
+	_, rotatedCert, rotatedKey, err := createTestCertBundle()
+	if err != nil {
+		t.Fatalf("createTestCertBundle failed with %s", err)
+	}
+
+	os.Setenv("TLS_CA_CERT_PATH", "certs/ca.pem")
+	os.Setenv("TLS_SERVER_CERT_PATH", "certs/client.pem")
+	os.Setenv("TLS_SERVER_KEY_PATH", "certs/client.key")
+	defer os.Unsetenv("TLS_CA_CERT_PATH")
+	defer os.Unsetenv("TLS_SERVER_CERT_PATH")
+	defer os.Unsetenv("TLS_SERVER_KEY_PATH")
+
+	setup := func() (afero.Fs, *CertificateWatcher) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "certs/ca.pem", caCert, 0644)
+		_ = afero.WriteFile(fs, "certs/client.pem", clientCert, 0644)
+		_ = afero.WriteFile(fs, "certs/client.key", clientKey, 0644)
+
+		return fs, NewCertificateWatcher(fs, &Controller{})
+	}
+
+	t.Run("Should publish rebuilt TLS config once certificates got rotated", func(t *testing.T) {
+		fs, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := watcher.Watch(ctx)
+
+		_ = afero.WriteFile(fs, "certs/client.pem", rotatedCert, 0644)
+		_ = afero.WriteFile(fs, "certs/client.key", rotatedKey, 0644)
+		watcher.Trigger()
+
+		select {
+		case cfg := <-updates:
+			assert.Len(t, cfg.Certificates, 1, "should contain the client cert")
+			expiry, _ := ClientCertificateExpiry(cfg)
+			assert.Equal(t, parseCertificates(rotatedCert)[0].NotAfter, expiry, "should contain the rotated client cert")
+		case <-time.After(time.Second):
+			t.Fatal("expected rebuilt TLS config to be published")
+		}
+	})
+
+	t.Run("Should not publish unchanged or partially rotated certificates", func(t *testing.T) {
+		fs, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := watcher.Watch(ctx)
+		watcher.Trigger()
+
+		// Key does not match the cert until it is rotated as well
+		_ = afero.WriteFile(fs, "certs/client.pem", rotatedCert, 0644)
+		watcher.Trigger()
+
+		select {
+		case <-updates:
+			t.Fatal("expected no TLS config to be published")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		_ = afero.WriteFile(fs, "certs/client.key", rotatedKey, 0644)
+		watcher.Trigger()
+
+		select {
+		case cfg := <-updates:
+			assert.Len(t, cfg.Certificates, 1, "should contain the client cert")
+		case <-time.After(time.Second):
+			t.Fatal("expected rebuilt TLS config to be published once rotation completed")
+		}
+	})
+
+	t.Run("Should close updates once context is done", func(t *testing.T) {
+		_, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		updates := watcher.Watch(ctx)
+		cancel()
+
+		select {
+		case _, open := <-updates:
+			assert.False(t, open, "should be closed")
+		case <-time.After(time.Second):
+			t.Fatal("expected updates to be closed")
+		}
+	})
+}
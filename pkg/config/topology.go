@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	internal "github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+)
+
+// ReadTopology reads the topology from the provided path, which is either a single file, a directory or a glob pattern.
+// The topologies of multiple files are merged, where an exchange may only be defined in one of them. Afterwards the
+// overlays listed in TOPOLOGY_OVERLAY are applied in order. Every file is checked against the topology schema, in strict
+// mode the topology is rejected if it contains any problem, like unknown fields or exchange types, otherwise the
+// problems are logged and the affected fields fall back to their defaults.
+func ReadTopology(fs afero.Fs, path string, strict bool) (internal.Topology, error) {
+	files, err := topologyFiles(fs, path)
+	if err != nil {
+		return internal.Topology{}, err
+	}
+
+	topology := internal.Topology{}
+	origin := make(map[string]string)
+	var duplicates []error
+
+	for _, file := range files {
+		read, err := readTopologyFile(fs, file, strict)
+		if err != nil {
+			return internal.Topology{}, err
+		}
+
+		for _, ex := range read {
+			if other, exists := origin[ex.Name]; exists && other != file {
+				duplicates = append(duplicates, fmt.Errorf("exchange %s is defined in both %s and %s", ex.Name, other, file))
+				continue
+			}
+			origin[ex.Name] = file
+			topology = append(topology, ex)
+		}
+	}
+
+	if len(duplicates) > 0 {
+		return internal.Topology{}, errors.Join(duplicates...)
+	}
+
+	for _, overlayPath := range overlayPaths() {
+		topology, err = applyOverlay(fs, topology, overlayPath)
+		if err != nil {
+			return internal.Topology{}, fmt.Errorf("overlay %s: %w", overlayPath, err)
+		}
+	}
+
+	return topology, nil
+}
+
+// TopologyReport lists the problems found in a topology or overlay file, definitions exports are not validated
+// against the TopologySchema
+type TopologyReport struct {
+	Path        string
+	Overlay     bool
+	Definitions bool
+	Problems    []internal.TopologyError
+}
+
+// ValidateTopology resolves the path like ReadTopology and strictly validates every file after interpolating
+// environment variables. Exchanges defined in more than one file are reported as problem of the later file. If the
+// files are valid, the overlays listed in TOPOLOGY_OVERLAY are applied in order and reported as well.
+func ValidateTopology(fs afero.Fs, path string) ([]TopologyReport, error) {
+	files, err := topologyFiles(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []TopologyReport
+	topology := internal.Topology{}
+	origin := make(map[string]string)
+	valid := true
+
+	for _, file := range files {
+		report := validateTopologyFile(fs, file)
+
+		if len(report.Problems) == 0 {
+			read, err := readTopologyFile(fs, file, false)
+			if err != nil {
+				report.Problems = append(report.Problems, internal.TopologyError{Message: err.Error()})
+			}
+
+			for _, ex := range read {
+				if other, exists := origin[ex.Name]; exists && other != file {
+					report.Problems = append(report.Problems, internal.TopologyError{Message: fmt.Sprintf("exchange %s is defined in both %s and %s", ex.Name, other, file)})
+					continue
+				}
+				origin[ex.Name] = file
+				topology = append(topology, ex)
+			}
+		}
+
+		valid = valid && len(report.Problems) == 0
+		reports = append(reports, report)
+	}
+
+	if !valid {
+		return reports, nil
+	}
+
+	for _, overlayPath := range overlayPaths() {
+		report := TopologyReport{Path: overlayPath, Overlay: true}
+
+		topology, err = applyOverlay(fs, topology, overlayPath)
+		if err != nil {
+			report.Problems = append(report.Problems, internal.TopologyError{Message: err.Error()})
+			return append(reports, report), nil
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// validateTopologyFile interpolates the file and validates it against the TopologySchema
+func validateTopologyFile(fs afero.Fs, path string) TopologyReport {
+	report := TopologyReport{Path: path}
+
+	data, err := afero.ReadFile(fs, path)
+	if err == nil {
+		data, err = internal.Interpolate(data)
+	}
+	if err != nil {
+		report.Problems = append(report.Problems, internal.TopologyError{Message: err.Error()})
+		return report
+	}
+
+	if strings.HasSuffix(path, ".json") && internal.IsDefinitions(data) {
+		report.Definitions = true
+		return report
+	}
+
+	report.Problems = internal.ValidateTopology(data)
+	return report
+}
+
+// overlayPaths returns the overlay files listed in TOPOLOGY_OVERLAY in order
+func overlayPaths() []string {
+	var paths []string
+
+	for _, overlayPath := range strings.Split(readFromEnv(envTopologyOverlay, ""), ",") {
+		overlayPath = strings.TrimSpace(overlayPath)
+		if len(overlayPath) > 0 {
+			paths = append(paths, overlayPath)
+		}
+	}
+
+	return paths
+}
+
+func applyOverlay(fs afero.Fs, topology internal.Topology, path string) (internal.Topology, error) {
+	overlay, err := internal.ReadOverlayFromFile(fs, path)
+	if err != nil {
+		return internal.Topology{}, err
+	}
+
+	return overlay.Apply(topology)
+}
+
+// topologyFiles resolves the path into the topology files it refers to, in lexical order
+func topologyFiles(fs afero.Fs, path string) ([]string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := afero.Glob(fs, path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := filterTopologyFiles(fs, matches)
+		if len(files) == 0 {
+			return nil, fmt.Errorf("provided topology pattern %s does not match any .yaml, .yml or .json file", path)
+		}
+		return files, nil
+	}
+
+	info, err := fs.Stat(path)
+	if len(path) == 0 || err != nil || (!info.IsDir() && !isTopologyFile(info.Name())) {
+		return nil, errors.New("provided topology is either non existing or does not end with .yaml, .yml or .json")
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := afero.ReadDir(fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, entry := range entries {
+		candidates = append(candidates, filepath.Join(path, entry.Name()))
+	}
+
+	files := filterTopologyFiles(fs, candidates)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("provided topology directory %s does not contain any .yaml, .yml or .json file", path)
+	}
+	return files, nil
+}
+
+func filterTopologyFiles(fs afero.Fs, candidates []string) []string {
+	var files []string
+
+	for _, candidate := range candidates {
+		if info, err := fs.Stat(candidate); err == nil && !info.IsDir() && isTopologyFile(info.Name()) {
+			files = append(files, candidate)
+		}
+	}
+
+	return files
+}
+
+func isTopologyFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".json")
+}
+
+func readTopologyFile(fs afero.Fs, path string, strict bool) (internal.Topology, error) {
+	if strings.HasSuffix(path, ".json") && isDefinitions(fs, path) {
+		vhost := readFromEnv(envDefinitionsVHost, readFromEnv(envRabbitVHost, ""))
+		if len(vhost) == 0 {
+			vhost = "/"
+		}
+
+		return internal.ReadTopologyFromDefinitions(fs, path, vhost, readFromEnv(envDefinitionsPrefix, ""))
+	}
+
+	if strict {
+		return internal.ReadTopologyFromFileStrict(fs, path)
+	}
+
+	warnAboutProblems(fs, path)
+	return internal.ReadTopologyFromFile(fs, path)
+}
+
+// warnAboutProblems logs the problems the schema reports for the topology file, which strict mode would reject
+func warnAboutProblems(fs afero.Fs, path string) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return
+	}
+
+	data, err = internal.Interpolate(data)
+	if err != nil {
+		return
+	}
+
+	for _, problem := range internal.ValidateTopology(data) {
+		log.Printf("Topology %s does not match the schema, %s. Will fall back to defaults, set %s to reject it", path, problem, envStrictTopology)
+	}
+}
+
+// isDefinitions reports whether the json file is a RabbitMQ definitions export, which is an object instead of a list
+func isDefinitions(fs afero.Fs, path string) bool {
+	data, err := afero.ReadFile(fs, path)
+	return err == nil && internal.IsDefinitions(data)
+}
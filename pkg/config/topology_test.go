@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	internal "github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTopology(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "topology/billing.yaml", []byte(`- name: ${TEST_PREFIX:-Dev}Billing
+  topics: [Foo]
+  declare: true`), 0644)
+	_ = afero.WriteFile(fs, "topology/orders.yaml", []byte(`- name: Orders
+  topics: [Bar]
+  declare: true`), 0644)
+	_ = afero.WriteFile(fs, "topology/README.md", []byte(`Not a topology`), 0644)
+	_ = afero.WriteFile(fs, "duplicate/a.yaml", []byte(`- name: Orders
+  topics: [Bar]`), 0644)
+	_ = afero.WriteFile(fs, "duplicate/b.yaml", []byte(`- name: Orders
+  topics: [Baz]`), 0644)
+	_ = afero.WriteFile(fs, "overlay/prod.yaml", []byte(`- name: Orders
+  durable: true
+- name: DevBilling
+  remove: true`), 0644)
+
+	t.Run("Should read yml and json topologies", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "formats/a.yml", []byte(`- name: AEx
+  topics: [Foo]`), 0644)
+		_ = afero.WriteFile(fs, "formats/b.json", []byte(`[{"name": "BEx", "topics": ["Bar"], "type": "topic", "durable": true}]`), 0644)
+
+		actual, err := ReadTopology(fs, "formats", true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, internal.Topology{
+			{Name: "AEx", Topics: []string{"Foo"}},
+			{Name: "BEx", Topics: []string{"Bar"}, Type: "topic", Durable: true},
+		}, actual)
+	})
+
+	t.Run("Should reject json topology violating the schema in strict mode", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "invalid.json", []byte(`[{"name": "BEx", "topics": []}]`), 0644)
+
+		_, err := ReadTopology(fs, "invalid.json", true)
+
+		assert.ErrorContains(t, err, `line 1: field "topics" has to contain at least one topic`)
+	})
+
+	t.Run("Should log problems of topology violating the schema outside of strict mode", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "lenient.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  type: fanout`), 0644)
+		output := new(bytes.Buffer)
+		log.SetOutput(output)
+		defer log.SetOutput(os.Stderr)
+
+		actual, err := ReadTopology(fs, "lenient.yaml", false)
+
+		assert.NoError(t, err)
+		assert.Len(t, actual, 1, "Should still read topology")
+		assert.Contains(t, output.String(), `Topology lenient.yaml does not match the schema, line 3: unknown exchange type "fanout"`)
+		assert.Contains(t, output.String(), "set TOPOLOGY_STRICT to reject it")
+	})
+
+	t.Run("Should merge files of directory", func(t *testing.T) {
+		actual, err := ReadTopology(fs, "topology", false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, internal.Topology{
+			{Name: "DevBilling", Topics: []string{"Foo"}, Declare: true},
+			{Name: "Orders", Topics: []string{"Bar"}, Declare: true},
+		}, actual)
+	})
+
+	t.Run("Should merge files matching glob", func(t *testing.T) {
+		os.Setenv("TEST_PREFIX", "Prod")
+		defer os.Unsetenv("TEST_PREFIX")
+
+		actual, err := ReadTopology(fs, "topology/b*.yaml", false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, internal.Topology{{Name: "ProdBilling", Topics: []string{"Foo"}, Declare: true}}, actual)
+	})
+
+	t.Run("Should fail for glob without matches", func(t *testing.T) {
+		_, err := ReadTopology(fs, "topology/*.json", false)
+
+		assert.EqualError(t, err, "provided topology pattern topology/*.json does not match any .yaml, .yml or .json file")
+	})
+
+	t.Run("Should fail for exchange defined in multiple files", func(t *testing.T) {
+		_, err := ReadTopology(fs, "duplicate", false)
+
+		assert.EqualError(t, err, "exchange Orders is defined in both duplicate/a.yaml and duplicate/b.yaml")
+	})
+
+	t.Run("Should apply overlays", func(t *testing.T) {
+		os.Setenv("TOPOLOGY_OVERLAY", "overlay/prod.yaml")
+		defer os.Unsetenv("TOPOLOGY_OVERLAY")
+
+		actual, err := ReadTopology(fs, "topology", false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, internal.Topology{{Name: "Orders", Topics: []string{"Bar"}, Declare: true, Durable: true}}, actual)
+	})
+
+	t.Run("Should fail for missing overlay", func(t *testing.T) {
+		os.Setenv("TOPOLOGY_OVERLAY", "overlay/missing.yaml")
+		defer os.Unsetenv("TOPOLOGY_OVERLAY")
+
+		_, err := ReadTopology(fs, "topology", false)
+
+		assert.ErrorContains(t, err, "overlay overlay/missing.yaml")
+	})
+}
+
+func TestValidateTopology(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "topology/billing.yaml", []byte(`- name: Billing
+  topics: [Foo]
+  type: ${TEST_TYPE:-direct}`), 0644)
+	_ = afero.WriteFile(fs, "topology/orders.yaml", []byte(`- name: Orders
+  topics: [Bar]`), 0644)
+	_ = afero.WriteFile(fs, "duplicate/a.yaml", []byte(`- name: Orders
+  topics: [Bar]`), 0644)
+	_ = afero.WriteFile(fs, "duplicate/b.yaml", []byte(`- name: Orders
+  topics: [Baz]`), 0644)
+	_ = afero.WriteFile(fs, "overlay/prod.yaml", []byte(`- name: Unknown
+  durable: true`), 0644)
+
+	t.Run("Should validate every file of directory after interpolating it", func(t *testing.T) {
+		os.Setenv("TEST_TYPE", "fanout")
+		defer os.Unsetenv("TEST_TYPE")
+
+		reports, err := ValidateTopology(fs, "topology")
+
+		assert.NoError(t, err)
+		assert.Len(t, reports, 2)
+		assert.Equal(t, []internal.TopologyError{{Line: 3, Message: `unknown exchange type "fanout", has to be either direct or topic`}}, reports[0].Problems)
+		assert.Equal(t, "topology/orders.yaml", reports[1].Path)
+		assert.Empty(t, reports[1].Problems)
+	})
+
+	t.Run("Should report exchange defined in multiple files", func(t *testing.T) {
+		reports, err := ValidateTopology(fs, "duplicate")
+
+		assert.NoError(t, err)
+		assert.Empty(t, reports[0].Problems)
+		assert.Equal(t, []internal.TopologyError{{Message: "exchange Orders is defined in both duplicate/a.yaml and duplicate/b.yaml"}}, reports[1].Problems)
+	})
+
+	t.Run("Should report overlays that do not apply", func(t *testing.T) {
+		os.Setenv("TOPOLOGY_OVERLAY", "overlay/prod.yaml")
+		defer os.Unsetenv("TOPOLOGY_OVERLAY")
+
+		reports, err := ValidateTopology(fs, "topology")
+
+		assert.NoError(t, err)
+		assert.Len(t, reports, 3)
+		assert.True(t, reports[2].Overlay)
+		assert.Len(t, reports[2].Problems, 1)
+	})
+
+	t.Run("Should fail for path without topology", func(t *testing.T) {
+		_, err := ValidateTopology(fs, "topology/*.json")
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"log"
+	"reflect"
+
+	internal "github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
+)
+
+// TopologyWatcher observes the topology files and overlays and publishes the topology every time it changes
+type TopologyWatcher struct {
+	*fileWatcher
+	path   string
+	strict bool
+
+	current internal.Topology
+}
+
+// NewTopologyWatcher creates a new watcher for the topology configured in the provided config. The topology of the
+// config is used as the baseline, so only changes compared to it are published. A watch interval of 0 disables polling,
+// in which case the topology is only re-read when Trigger is called.
+func NewTopologyWatcher(fs afero.Fs, conf *Controller) *TopologyWatcher {
+	path := conf.TopologyPath
+
+	return &TopologyWatcher{
+		fileWatcher: newFileWatcher(fs, "topology", conf.TopologyWatchInterval, func() ([]string, error) {
+			files, err := topologyFiles(fs, path)
+			if err != nil {
+				return nil, err
+			}
+			return append(files, overlayPaths()...), nil
+		}),
+		path:   path,
+		strict: conf.StrictTopology,
+
+		current: conf.Topology,
+	}
+}
+
+// Watch starts observing the topology until the context is done. Changed topologies are published on the returned channel,
+// which is closed once watching stopped. Topologies that fail to be read are logged and ignored.
+func (w *TopologyWatcher) Watch(ctx context.Context) <-chan internal.Topology {
+	updates := make(chan internal.Topology)
+
+	go func() {
+		defer close(updates)
+
+		w.watch(ctx, func() bool {
+			topology, err := ReadTopology(w.fs, w.path, w.strict)
+			if err != nil {
+				log.Printf("Received %s while reading topology from %s, will keep the current topology", err, w.path)
+				return false
+			}
+
+			if reflect.DeepEqual(topology, w.current) {
+				return true
+			}
+
+			log.Printf("Detected changed topology at %s", w.path)
+			w.current = topology
+			select {
+			case updates <- topology:
+			case <-ctx.Done():
+			}
+			return true
+		})
+	}()
+
+	return updates
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopologyWatcher_Watch(t *testing.T) {
+	const path = "config/topology.yaml"
+
+	setup := func() (afero.Fs, *TopologyWatcher) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, path, []byte(`- name: AEx
+  topics: [Foo, Bar]
+  declare: true`), 0644)
+
+		initial, _ := ReadTopology(fs, path, true)
+		return fs, NewTopologyWatcher(fs, &Controller{TopologyPath: path, StrictTopology: true, Topology: initial})
+	}
+
+	t.Run("Should publish topology once it changed", func(t *testing.T) {
+		fs, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := watcher.Watch(ctx)
+
+		_ = afero.WriteFile(fs, path, []byte(`- name: AEx
+  topics: [Foo]
+  declare: true`), 0644)
+		watcher.Trigger()
+
+		select {
+		case topology := <-updates:
+			assert.Len(t, topology, 1, "should contain one exchange")
+			assert.Equal(t, []string{"Foo"}, topology[0].Topics, "should contain the updated topics")
+		case <-time.After(time.Second):
+			t.Fatal("expected changed topology to be published")
+		}
+	})
+
+	t.Run("Should publish topology once an overlay changed", func(t *testing.T) {
+		os.Setenv("TOPOLOGY_OVERLAY", "config/overlay.yaml")
+		defer os.Unsetenv("TOPOLOGY_OVERLAY")
+
+		fs, watcher := setup()
+		_ = afero.WriteFile(fs, "config/overlay.yaml", []byte(`[]`), 0644)
+		watcher = NewTopologyWatcher(fs, &Controller{TopologyPath: path, StrictTopology: true, Topology: watcher.current})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := watcher.Watch(ctx)
+
+		_ = afero.WriteFile(fs, "config/overlay.yaml", []byte(`- name: AEx
+  durable: true`), 0644)
+		watcher.Trigger()
+
+		select {
+		case topology := <-updates:
+			assert.True(t, topology[0].Durable, "should apply the changed overlay")
+		case <-time.After(time.Second):
+			t.Fatal("expected changed topology to be published")
+		}
+	})
+
+	t.Run("Should not publish unchanged or invalid topology", func(t *testing.T) {
+		fs, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		updates := watcher.Watch(ctx)
+		watcher.Trigger()
+
+		_ = afero.WriteFile(fs, path, []byte(`{ invalid`), 0644)
+		watcher.Trigger()
+
+		select {
+		case <-updates:
+			t.Fatal("expected no topology to be published")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Should close updates once context is done", func(t *testing.T) {
+		_, watcher := setup()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		updates := watcher.Watch(ctx)
+		cancel()
+
+		select {
+		case _, open := <-updates:
+			assert.False(t, open, "should be closed")
+		case <-time.After(time.Second):
+			t.Fatal("expected updates to be closed")
+		}
+	})
+}
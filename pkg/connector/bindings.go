@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package connector
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+)
+
+// SyncBindings replaces the bindings derived from function annotations and applies them together with the static topology.
+// Queues of topics that lost their last subscriber are deleted once the configured grace period passed.
+func (c *Connector) SyncBindings(bindings map[string][]string) {
+	c.lock.Lock()
+
+	c.scheduleQueueDeletion(c.dynamic, bindings)
+	c.dynamic = bindings
+
+	if !c.running {
+		// Will be picked up once the connector (re)starts
+		c.lock.Unlock()
+		return
+	}
+
+	retired, err := c.apply()
+	c.lock.Unlock()
+
+	if err != nil {
+		log.Printf("Received %s while applying bindings derived from functions", err)
+	}
+	drain(retired)
+}
+
+// effectiveTopology merges the static topology with the bindings derived from function annotations. Topics for exchanges
+// that are part of the static topology are added to them, other exchanges are expected to already exist on RabbitMQ.
+func (c *Connector) effectiveTopology() types.Topology {
+	if len(c.dynamic) == 0 {
+		return c.conf.Topology
+	}
+
+	merged := make(types.Topology, 0, len(c.conf.Topology)+len(c.dynamic))
+	known := make(map[string]int)
+
+	for _, definition := range c.conf.Topology {
+		definition.Topics = append([]string{}, definition.Topics...)
+		known[definition.Name] = len(merged)
+		merged = append(merged, definition)
+	}
+
+	exchanges := make([]string, 0, len(c.dynamic))
+	for exchange := range c.dynamic {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	for _, exchange := range exchanges {
+		// Sorting keeps the definition stable between refreshes, as functions are crawled in no particular order
+		topics := append([]string{}, c.dynamic[exchange]...)
+		sort.Strings(topics)
+
+		if idx, exists := known[exchange]; exists {
+			for _, topic := range topics {
+				if !contains(merged[idx].Topics, topic) {
+					merged[idx].Topics = append(merged[idx].Topics, topic)
+				}
+			}
+			continue
+		}
+
+		merged = append(merged, types.Exchange{
+			Name:    exchange,
+			Topics:  topics,
+			Declare: false,
+			Type:    "topic",
+			Durable: true,
+		})
+	}
+
+	return merged
+}
+
+// scheduleQueueDeletion schedules the deletion of queues for topics that lost their last subscriber
+// and cancels pending deletions for topics that got a subscriber again.
+func (c *Connector) scheduleQueueDeletion(previous map[string][]string, next map[string][]string) {
+	if c.conf.DynamicQueueGracePeriod <= 0 {
+		return
+	}
+
+	for exchange, topics := range next {
+		for _, topic := range topics {
+			name := rabbitmq.GenerateQueueName(exchange, topic)
+			if timer, pending := c.pendingDeletes[name]; pending {
+				log.Printf("Queue %s got a subscriber again, will not delete it", name)
+				timer.Stop()
+				delete(c.pendingDeletes, name)
+			}
+		}
+	}
+
+	for exchange, topics := range previous {
+		for _, topic := range topics {
+			name := rabbitmq.GenerateQueueName(exchange, topic)
+			if contains(next[exchange], topic) || c.isStatic(exchange, topic) {
+				continue
+			}
+
+			if _, pending := c.pendingDeletes[name]; pending {
+				continue
+			}
+
+			log.Printf("Queue %s lost its last subscriber, will delete it in %s", name, c.conf.DynamicQueueGracePeriod)
+			exchange, topic := exchange, topic
+			c.pendingDeletes[name] = time.AfterFunc(c.conf.DynamicQueueGracePeriod, func() {
+				c.deleteQueue(exchange, topic)
+			})
+		}
+	}
+}
+
+// deleteQueue deletes the queue for the topic unless it got a subscriber in the meantime. Queues that still
+// have consumers are kept.
+func (c *Connector) deleteQueue(exchange string, topic string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	name := rabbitmq.GenerateQueueName(exchange, topic)
+	delete(c.pendingDeletes, name)
+
+	if contains(c.dynamic[exchange], topic) || c.isStatic(exchange, topic) {
+		return
+	}
+
+	channel, err := c.conManager.Channel()
+	if err != nil {
+		log.Printf("Received %s while opening channel to delete queue %s", err, name)
+		return
+	}
+	defer channel.Close()
+
+	purged, err := channel.QueueDelete(name, true, false, false)
+	if err != nil {
+		log.Printf("Received %s while deleting queue %s", err, name)
+		return
+	}
+
+	log.Printf("Successfully deleted queue %s dropping %d message(s)", name, purged)
+}
+
+func (c *Connector) isStatic(exchange string, topic string) bool {
+	for _, definition := range c.conf.Topology {
+		if definition.Name == exchange && contains(definition.Topics, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+
+	return false
+}
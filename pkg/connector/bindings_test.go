@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package connector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type channelMock struct {
+	mock.Mock
+}
+
+func (ch *channelMock) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	params := ch.Called(name, durable, autoDelete, exclusive, noWait, args)
+	return params.Get(0).(amqp.Queue), params.Error(1)
+}
+
+func (ch *channelMock) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	params := ch.Called(name, durable, autoDelete, exclusive, noWait, args)
+	return params.Get(0).(amqp.Queue), params.Error(1)
+}
+
+func (ch *channelMock) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	params := ch.Called(name, key, exchange, noWait, args)
+	return params.Error(0)
+}
+
+func (ch *channelMock) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	params := ch.Called(name, ifUnused, ifEmpty, noWait)
+	return params.Int(0), params.Error(1)
+}
+
+func (ch *channelMock) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	params := ch.Called(name, kind, durable, autoDelete, internal, noWait, args)
+	return params.Error(0)
+}
+
+func (ch *channelMock) ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	params := ch.Called(name, kind, durable, autoDelete, internal, noWait, args)
+	return params.Error(0)
+}
+
+func (ch *channelMock) ExchangeDelete(name string, ifUnused, noWait bool) error {
+	params := ch.Called(name, ifUnused, noWait)
+	return params.Error(0)
+}
+
+func (ch *channelMock) Consume(queue string, consumer string, autoAck, exclusive bool, noLocal bool, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	params := ch.Called(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+	return params.Get(0).(<-chan amqp.Delivery), params.Error(1)
+}
+
+func (ch *channelMock) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	args := ch.Called(c)
+	return args.Get(0).(chan *amqp.Error)
+}
+
+func (ch *channelMock) Close() error {
+	args := ch.Called(nil)
+	return args.Error(0)
+}
+
+func TestConnector_effectiveTopology(t *testing.T) {
+	conf := config.Controller{
+		Topology: types.Topology{{Name: "Nasdaq", Topics: []string{"Billing"}, Declare: true, Type: "direct"}},
+	}
+
+	t.Run("Should return static topology if there are no bindings", func(t *testing.T) {
+		target := &Connector{conf: &conf}
+
+		assert.Equal(t, conf.Topology, target.effectiveTopology())
+	})
+
+	t.Run("Should merge bindings into static topology", func(t *testing.T) {
+		target := &Connector{
+			conf: &conf,
+			dynamic: map[string][]string{
+				"Nasdaq":    {"Transport", "Billing"},
+				"amq.topic": {"Foo", "Bar"},
+			},
+		}
+
+		actual := target.effectiveTopology()
+
+		assert.Equal(t, types.Topology{
+			{Name: "Nasdaq", Topics: []string{"Billing", "Transport"}, Declare: true, Type: "direct"},
+			{Name: "amq.topic", Topics: []string{"Bar", "Foo"}, Declare: false, Type: "topic", Durable: true},
+		}, actual)
+		assert.Equal(t, []string{"Billing"}, conf.Topology[0].Topics, "should not modify static topology")
+	})
+}
+
+func TestConnector_Queues(t *testing.T) {
+	t.Run("Should return queues of static topology and bindings", func(t *testing.T) {
+		target := &Connector{
+			conf:    &config.Controller{Topology: types.Topology{{Name: "Nasdaq", Topics: []string{"Billing"}}}},
+			dynamic: map[string][]string{"amq.topic": {"Foo"}},
+		}
+
+		assert.Equal(t, []string{"OpenFaaS_Nasdaq_Billing", "OpenFaaS_amq.topic_Foo"}, target.Queues())
+	})
+
+	t.Run("Should follow reloaded topologies", func(t *testing.T) {
+		target := &Connector{conf: &config.Controller{}}
+
+		_ = target.Reload(types.Topology{{Name: "Dax", Topics: []string{"Transport"}}})
+
+		assert.Equal(t, []string{"OpenFaaS_Dax_Transport"}, target.Queues())
+	})
+}
+
+func TestConnector_SyncBindings(t *testing.T) {
+	t.Run("Should only remember bindings while not running", func(t *testing.T) {
+		factory := new(factoryMock)
+		target := &Connector{conf: &config.Controller{}, factory: factory}
+
+		target.SyncBindings(map[string][]string{"amq.topic": {"Foo"}})
+
+		assert.Equal(t, map[string][]string{"amq.topic": {"Foo"}}, target.dynamic)
+		factory.AssertNotCalled(t, "Build", nil)
+	})
+
+	t.Run("Should start consuming for new bindings while running", func(t *testing.T) {
+		exchange := new(exchangeMock)
+		exchange.On("Start", nil).Return(nil)
+
+		factory := new(factoryMock)
+		factory.On("WithInvoker", nil)
+		factory.On("WithChanCreator", nil)
+		factory.On("WithExchange", nil)
+		factory.On("Build", nil).Return(exchange, nil)
+
+		target := &Connector{
+			running: true,
+			conf:    &config.Controller{},
+			factory: factory,
+
+			exchanges: map[string]rabbitmq.ExchangeOrganizer{},
+			topology:  map[string]types.Exchange{},
+		}
+
+		target.SyncBindings(map[string][]string{"amq.topic": {"Foo"}})
+
+		assert.Same(t, exchange, target.exchanges["amq.topic"], "should track exchange")
+		exchange.AssertExpectations(t)
+	})
+
+	t.Run("Should delete queue after grace period once last subscriber is gone", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("QueueDelete", "OpenFaaS_amq.topic_Foo", true, false, false).Return(3, nil)
+		channel.On("Close", nil).Return(nil)
+
+		manager := new(managerMock)
+		manager.On("Channel", nil).Return(channel, nil)
+
+		target := &Connector{
+			conf:       &config.Controller{DynamicQueueGracePeriod: 10 * time.Millisecond},
+			conManager: manager,
+
+			dynamic:        map[string][]string{"amq.topic": {"Foo"}},
+			pendingDeletes: map[string]*time.Timer{},
+		}
+
+		target.SyncBindings(map[string][]string{})
+		time.Sleep(100 * time.Millisecond)
+
+		channel.AssertExpectations(t)
+		manager.AssertExpectations(t)
+	})
+
+	t.Run("Should not delete queue if topic got a subscriber again", func(t *testing.T) {
+		manager := new(managerMock)
+
+		target := &Connector{
+			conf:       &config.Controller{DynamicQueueGracePeriod: 50 * time.Millisecond},
+			conManager: manager,
+
+			dynamic:        map[string][]string{"amq.topic": {"Foo"}},
+			pendingDeletes: map[string]*time.Timer{},
+		}
+
+		target.SyncBindings(map[string][]string{})
+		target.SyncBindings(map[string][]string{"amq.topic": {"Foo"}})
+		time.Sleep(100 * time.Millisecond)
+
+		assert.Empty(t, target.pendingDeletes, "should not have pending deletes")
+		manager.AssertNotCalled(t, "Channel", nil)
+	})
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package connector
+
+import (
+	"crypto/tls"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
+)
+
+// CertificateRotation applies rotated TLS configs to the connection manager and optionally reconnects the connector,
+// before the client certificate of the current connection expires.
+type CertificateRotation struct {
+	connector RabbitToOpenFaaS
+	manager   rabbitmq.Manager
+	margin    time.Duration
+
+	lock   sync.Mutex
+	inUse  *tls.Config
+	latest *tls.Config
+	timer  *time.Timer
+}
+
+// NewCertificateRotation creates a new rotation for the TLS config the connector is currently connected with. A margin of
+// 0 disables reconnecting, in which case rotated certificates are only used once the connection is re-established anyway.
+func NewCertificateRotation(c RabbitToOpenFaaS, manager rabbitmq.Manager, current *tls.Config, margin time.Duration) *CertificateRotation {
+	return &CertificateRotation{
+		connector: c,
+		manager:   manager,
+		margin:    margin,
+
+		inUse:  current,
+		latest: current,
+	}
+}
+
+// Apply hands the rotated TLS config to the connection manager, so further connections use it. If reconnecting is enabled,
+// a reconnect is scheduled the margin before the client certificate of the current connection expires, or right away if
+// that point already passed.
+func (r *CertificateRotation) Apply(cfg *tls.Config) {
+	r.manager.UpdateTLSConfig(cfg)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.latest = cfg
+	if r.margin <= 0 || r.timer != nil {
+		// A pending reconnect picks up the latest config
+		return
+	}
+
+	expiry, ok := config.ClientCertificateExpiry(r.inUse)
+	if !ok {
+		return
+	}
+
+	delay := time.Until(expiry.Add(-r.margin))
+	if delay < 0 {
+		delay = 0
+	}
+
+	log.Printf("Will reconnect with the rotated certificate in %s, the current one expires at %s", delay.Round(time.Second), expiry.Format(time.RFC3339))
+	r.timer = time.AfterFunc(delay, r.reconnect)
+}
+
+// Stop cancels a pending reconnect
+func (r *CertificateRotation) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+func (r *CertificateRotation) reconnect() {
+	r.lock.Lock()
+	r.timer = nil
+	r.inUse = r.latest
+	r.lock.Unlock()
+
+	if err := r.connector.Reconnect(); err != nil {
+		log.Printf("Received %s while reconnecting with the rotated certificate", err)
+	}
+}
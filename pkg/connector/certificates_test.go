@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package connector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func tlsConfigExpiringAt(t *testing.T, notAfter time.Time) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key failed with %s", err)
+	}
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: notAfter.AddDate(0, 0, -30), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate failed with %s", err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func TestCertificateRotation_Apply(t *testing.T) {
+	t.Run("Should hand rotated config to the manager without reconnecting if disabled", func(t *testing.T) {
+		rotated := tlsConfigExpiringAt(t, time.Now().AddDate(0, 0, 30))
+
+		manager := new(managerMock)
+		manager.On("UpdateTLSConfig", rotated)
+
+		target := NewCertificateRotation(&Connector{running: true, conManager: manager}, manager, tlsConfigExpiringAt(t, time.Now()), 0)
+		target.Apply(rotated)
+
+		assert.Nil(t, target.timer, "should not schedule reconnect")
+		manager.AssertExpectations(t)
+	})
+
+	t.Run("Should schedule reconnect before the current certificate expires", func(t *testing.T) {
+		rotated := tlsConfigExpiringAt(t, time.Now().AddDate(0, 0, 30))
+
+		manager := new(managerMock)
+		manager.On("UpdateTLSConfig", rotated)
+
+		target := NewCertificateRotation(&Connector{conManager: manager}, manager, tlsConfigExpiringAt(t, time.Now().Add(48*time.Hour)), 24*time.Hour)
+		target.Apply(rotated)
+		defer target.Stop()
+
+		assert.NotNil(t, target.timer, "should schedule reconnect")
+	})
+
+	t.Run("Should reconnect right away if the current certificate is about to expire", func(t *testing.T) {
+		rotated := tlsConfigExpiringAt(t, time.Now().AddDate(0, 0, 30))
+		conf := &config.Controller{RabbitConnectionURLs: []string{"amqps://localhost:5671/"}}
+
+		manager := new(managerMock)
+		manager.On("UpdateTLSConfig", rotated)
+		manager.On("Replace", conf.RabbitConnectionURLs).Return(make(<-chan *amqp.Error), func() {}, nil)
+
+		factory := new(factoryMock)
+		factory.On("WithInvoker", nil)
+		factory.On("WithChanCreator", nil)
+
+		c := New(manager, factory, nil, conf).(*Connector)
+		c.running = true
+
+		target := NewCertificateRotation(c, manager, tlsConfigExpiringAt(t, time.Now().Add(time.Hour)), 24*time.Hour)
+		target.Apply(rotated)
+
+		assert.Eventually(t, func() bool {
+			target.lock.Lock()
+			defer target.lock.Unlock()
+			return target.inUse == rotated
+		}, time.Second, 10*time.Millisecond, "should reconnect with rotated config")
+		assert.Eventually(t, c.Ready, time.Second, 10*time.Millisecond, "should be running again")
+		manager.AssertExpectations(t)
+	})
+
+	t.Run("Should not reconnect without client certificate", func(t *testing.T) {
+		rotated := &tls.Config{}
+
+		manager := new(managerMock)
+		manager.On("UpdateTLSConfig", rotated)
+
+		target := NewCertificateRotation(&Connector{}, manager, &tls.Config{}, 24*time.Hour)
+		target.Apply(rotated)
+
+		assert.Nil(t, target.timer, "should not schedule reconnect")
+	})
+}
@@ -6,7 +6,12 @@
 package connector
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"sync"
+	"time"
 
 	"github.com/Templum/rabbitmq-connector/pkg/config"
 	"github.com/Templum/rabbitmq-connector/pkg/rabbitmq"
@@ -17,6 +22,12 @@ import (
 // RabbitToOpenFaaS defines the basic interactions for the connector
 type RabbitToOpenFaaS interface {
 	Run() error
+	Reload(t types.Topology) error
+	SyncBindings(bindings map[string][]string)
+	Reconnect() error
+	UpdateConnectionURLs(urls []string, sanitized string) error
+	Ready() bool
+	Queues() []string
 	Shutdown()
 }
 
@@ -28,6 +39,10 @@ func New(manager rabbitmq.Manager, factory rabbitmq.Factory, invoker types.Invok
 		factory:    factory,
 		conManager: manager,
 		conf:       conf,
+		exchanges:  make(map[string]rabbitmq.ExchangeOrganizer),
+		topology:   make(map[string]types.Exchange),
+
+		pendingDeletes: make(map[string]*time.Timer),
 	}
 }
 
@@ -38,26 +53,116 @@ type Connector struct {
 	factory    rabbitmq.Factory
 	conManager rabbitmq.Manager
 	conf       *config.Controller
-	exchanges  []rabbitmq.ExchangeOrganizer
+
+	lock      sync.Mutex
+	running   bool
+	closed    bool
+	exchanges map[string]rabbitmq.ExchangeOrganizer
+	topology  map[string]types.Exchange
+
+	dynamic        map[string][]string
+	pendingDeletes map[string]*time.Timer
 }
 
 // Run starts the connector and creates a connection RabbitMQ. Further it implements the defined Topology.
 // Also it adds a listener that handles connection failures.
 func (c *Connector) Run() error {
 	log.Println("Started RabbitMQ <=> OpenFaaS Connector")
-	log.Printf("Will now establish connection to %s", c.conf.RabbitSanitizedURL)
+	urls, sanitized := c.connectionURLs()
+	log.Printf("Will now establish connection to %s", sanitized)
+
+	failureChan, conErr := c.conManager.Connect(urls)
+	if conErr != nil {
+		return conErr
+	}
+
+	go c.HandleConnectionError(failureChan)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.consume()
+}
 
-	failureChan, conErr := c.conManager.Connect(c.conf.RabbitConnectionURL)
+// Reconnect gracefully replaces the connection of the running connector, e.g. to pick up rotated certificates. The new
+// connection is established first, so the connector keeps consuming on the current one until it succeeded. Afterwards
+// the exchanges are rebuilt on the new connection, while the ones on the current connection are drained, so deliveries
+// in flight are still acknowledged before it is closed. While the connector is not running it does nothing, as
+// (re)connecting already picks up the current config.
+func (c *Connector) Reconnect() error {
+	if !c.Ready() {
+		return nil
+	}
+
+	urls, sanitized := c.connectionURLs()
+	log.Printf("Will reconnect to %s", sanitized)
+
+	// Closing the replaced connection ends its error handler without attempting a recovery
+	failureChan, release, conErr := c.conManager.Replace(urls)
 	if conErr != nil {
 		return conErr
 	}
+	defer release()
 
 	go c.HandleConnectionError(failureChan)
 
-	genErr := c.generateExchangesFrom(c.conf.Topology)
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+
+	previous := c.exchanges
+	c.exchanges = make(map[string]rabbitmq.ExchangeOrganizer)
+	c.topology = make(map[string]types.Exchange)
+
+	err := c.consume()
+	c.lock.Unlock()
+
+	drain(previous)
+	return err
+}
+
+// UpdateConnectionURLs replaces the urls the connector connects with, e.g. after the credentials got rotated, and
+// gracefully reconnects the running connector with them.
+func (c *Connector) UpdateConnectionURLs(urls []string, sanitized string) error {
+	c.lock.Lock()
+	c.conf.RabbitConnectionURLs = urls
+	c.conf.RabbitSanitizedURL = sanitized
+	c.lock.Unlock()
+
+	return c.Reconnect()
+}
+
+func (c *Connector) connectionURLs() ([]string, string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.conf.RabbitConnectionURLs, c.conf.RabbitSanitizedURL
+}
+
+// drain gracefully stops the exchanges in parallel, as every one of them waits for its deliveries in flight
+func drain(exchanges map[string]rabbitmq.ExchangeOrganizer) {
+	var wg sync.WaitGroup
+
+	for _, ex := range exchanges {
+		wg.Add(1)
+		go func(ex rabbitmq.ExchangeOrganizer) {
+			defer wg.Done()
+			ex.Drain()
+		}(ex)
+	}
+
+	wg.Wait()
+}
+
+// consume builds the exchanges of the effective topology and starts them, callers have to hold the lock
+func (c *Connector) consume() error {
+	genErr := c.generateExchangesFrom(c.effectiveTopology())
 	if genErr != nil {
 		return genErr
 	}
+	c.running = true
 
 	for _, ex := range c.exchanges {
 		err := ex.Start()
@@ -69,26 +174,149 @@ func (c *Connector) Run() error {
 	return nil
 }
 
-// HandleConnectionError listens for incoming connection errors. If it is recoverable it will attempt a self-heal.
-// Otherwise it shutsdown the whole connector
+// Reload applies the provided topology to the running connector. Exchanges that are no longer part of the topology
+// are drained, new ones are started and changed ones are rebuilt. Exchanges that did not change keep consuming.
+func (c *Connector) Reload(t types.Topology) error {
+	c.lock.Lock()
+
+	if _, err := mapByName(t); err != nil {
+		c.lock.Unlock()
+		return err
+	}
+
+	c.conf.Topology = t
+	if !c.running {
+		// Will be picked up once the connector (re)starts
+		c.lock.Unlock()
+		return nil
+	}
+
+	retired, err := c.apply()
+	c.lock.Unlock()
+
+	drain(retired)
+	return err
+}
+
+// apply diffs the effective topology against the running exchanges and starts or rebuilds them accordingly, callers
+// have to hold the lock. Removed and replaced exchanges are returned, so callers can drain them once they released the
+// lock, as waiting for their deliveries in flight would otherwise block every other change to the connector.
+func (c *Connector) apply() (map[string]rabbitmq.ExchangeOrganizer, error) {
+	desired, err := mapByName(c.effectiveTopology())
+	if err != nil {
+		return nil, err
+	}
+
+	c.factory.WithChanCreator(c.conManager).WithInvoker(c.client)
+	retired := make(map[string]rabbitmq.ExchangeOrganizer)
+	var errs []error
+
+	for name, ex := range c.exchanges {
+		if _, exists := desired[name]; !exists {
+			log.Printf("Exchange %s was removed from topology, will drain it", name)
+			retired[name] = ex
+			delete(c.exchanges, name)
+			delete(c.topology, name)
+		}
+	}
+
+	for name, definition := range desired {
+		if current, exists := c.topology[name]; exists {
+			if reflect.DeepEqual(current, definition) {
+				continue
+			}
+
+			log.Printf("Exchange %s changed, will rebuild it and drain the current one", name)
+			retired[name] = c.exchanges[name]
+			delete(c.exchanges, name)
+			delete(c.topology, name)
+		} else {
+			log.Printf("Exchange %s was added to topology, will start consuming", name)
+		}
+
+		startErr := c.startExchange(definition)
+		if startErr != nil {
+			errs = append(errs, fmt.Errorf("exchange %s: %w", name, startErr))
+		}
+	}
+
+	return retired, errors.Join(errs...)
+}
+
+// HandleConnectionError listens for incoming connection errors and attempts a self-heal by restarting the connector,
+// which reconnects according to the reconnect policy. Only fatal errors, like refused credentials, or an exhausted
+// reconnect policy shut down the whole connector.
 func (c *Connector) HandleConnectionError(ch <-chan *amqp.Error) {
-	err := <-ch
+	err, ok := <-ch
+	if !ok || err == nil {
+		// Connection was closed on purpose
+		return
+	}
+
 	log.Printf("Rabbit MQ Connection failed with %s Code: %d [Server=%t Recover=%t]", err.Reason, err.Code, err.Server, err.Recover)
+	if rabbitmq.IsFatal(err) {
+		log.Panicf("Received critical error: %s, shutting down", err)
+	}
 
-	if err.Recover {
-		for _, ex := range c.exchanges {
-			ex.Stop()
+	for attempt := 1; ; attempt++ {
+		if !c.reset() {
+			return
 		}
 
-		// Release old exchange refs to garbage collection
-		c.exchanges = nil
-		err := c.Run()
-		if err != nil {
-			log.Panicf("Received critical error: %s during restart, shutting down", err)
+		runErr := c.Run()
+		switch {
+		case runErr == nil:
+			return
+		case errors.Is(runErr, rabbitmq.ErrDisconnected):
+			return
+		case rabbitmq.IsFatal(runErr), errors.Is(runErr, rabbitmq.ErrConnectionFailed), c.conf.Reconnect.Exhausted(attempt):
+			log.Panicf("Received critical error: %s during restart, shutting down", runErr)
 		}
-	} else {
-		log.Panicf("Received critical error: %s, shutting down", err)
+
+		delay := c.conf.Reconnect.Delay(attempt)
+		log.Printf("Received %s during restart, will retry in %s", runErr, delay)
+		time.Sleep(delay)
+	}
+}
+
+// reset stops and releases all exchanges, it reports false if the connector was shut down in the meantime
+func (c *Connector) reset() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, ex := range c.exchanges {
+		ex.Stop()
 	}
+
+	// Release old exchange refs to garbage collection
+	c.exchanges = make(map[string]rabbitmq.ExchangeOrganizer)
+	c.topology = make(map[string]types.Exchange)
+	c.running = false
+
+	return !c.closed
+}
+
+// Ready reports whether the connector is connected and consuming, it is not ready while (re)connecting
+func (c *Connector) Ready() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.running
+}
+
+// Queues returns the names of the queues of the effective topology, which includes reloads and dynamic bindings
+func (c *Connector) Queues() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var queues []string
+	for _, ex := range c.effectiveTopology() {
+		for _, topic := range ex.Topics {
+			queues = append(queues, rabbitmq.GenerateQueueName(ex.Name, topic))
+		}
+	}
+
+	return queues
 }
 
 // Shutdown is usually called during graceful shutdown. It stops all exchanges and finally closes the connection
@@ -97,9 +325,16 @@ func (c *Connector) Shutdown() {
 	log.Println("Shutdown RabbitMQ <=> OpenFaaS Connector")
 
 	// Loop over Exchanges to close
+	c.lock.Lock()
 	for _, ex := range c.exchanges {
 		ex.Stop()
 	}
+	for _, timer := range c.pendingDeletes {
+		timer.Stop()
+	}
+	c.running = false
+	c.closed = true
+	c.lock.Unlock()
 
 	// Close Connection
 	c.conManager.Disconnect()
@@ -109,16 +344,54 @@ func (c *Connector) generateExchangesFrom(t types.Topology) error {
 	// Do we want to use a connection per Exchange or continue with channels ?
 	c.factory.WithChanCreator(c.conManager).WithInvoker(c.client)
 
-	for _, topology := range c.conf.Topology {
-		tmp := types.Exchange(topology)
+	if _, err := mapByName(t); err != nil {
+		return err
+	}
+
+	for _, definition := range t {
+		tmp := definition
 		exchange, buildErr := c.factory.WithExchange(&tmp).Build()
 
 		if buildErr != nil {
 			return buildErr
 		}
 
-		c.exchanges = append(c.exchanges, exchange)
+		c.exchanges[definition.Name] = exchange
+		c.topology[definition.Name] = definition
 	}
 
 	return nil
 }
+
+// startExchange builds and starts the exchange, it is only tracked if it started successfully
+func (c *Connector) startExchange(definition types.Exchange) error {
+	tmp := definition
+	exchange, buildErr := c.factory.WithExchange(&tmp).Build()
+	if buildErr != nil {
+		return buildErr
+	}
+
+	startErr := exchange.Start()
+	if startErr != nil {
+		exchange.Stop()
+		return startErr
+	}
+
+	c.exchanges[definition.Name] = exchange
+	c.topology[definition.Name] = definition
+	return nil
+}
+
+// mapByName indexes the topology by exchange name, as the name is what identifies an exchange on RabbitMQ
+func mapByName(t types.Topology) (map[string]types.Exchange, error) {
+	out := make(map[string]types.Exchange, len(t))
+
+	for _, definition := range t {
+		if _, exists := out[definition.Name]; exists {
+			return nil, fmt.Errorf("exchange %s is defined more than once in the topology", definition.Name)
+		}
+		out[definition.Name] = definition
+	}
+
+	return out, nil
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package connector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHealthServer(t *testing.T) {
+	t.Run("Should report not ready while not running", func(t *testing.T) {
+		server := NewHealthServer(":0", &Connector{})
+		recorder := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, "not ready", recorder.Body.String())
+	})
+
+	t.Run("Should report ready while running", func(t *testing.T) {
+		server := NewHealthServer(":0", &Connector{running: true})
+		recorder := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Should always report alive", func(t *testing.T) {
+		server := NewHealthServer(":0", &Connector{})
+		recorder := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
@@ -0,0 +1,435 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package openfaas
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/openfaas/faas-provider/auth"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/valyala/fasthttp"
+)
+
+// AuthProvider supplies the Authorization header for requests towards the gateway, covering the /system/* endpoints
+// as well as function invocations.
+type AuthProvider interface {
+	Authorization(ctx context.Context) (string, error)
+}
+
+// Reloader is implemented by auth providers whose credentials can be rotated. Reload discards the current credentials
+// and reports whether the ones obtained afterwards differ.
+type Reloader interface {
+	Reload() (bool, error)
+}
+
+// NewAuthProvider creates the provider for the gateway auth described by the config, it returns nil if the gateway
+// does not require authentication. Tokens are requested with a client of their own, as the token endpoint neither
+// trusts the CA nor expects the client certificate of the gateway.
+func NewAuthProvider(fs afero.Fs, conf *config.Controller) AuthProvider {
+	switch conf.GatewayAuth {
+	case config.GatewayAuthBearer:
+		return NewBearerTokenFile(fs, conf.GatewayTokenFile)
+	case config.GatewayAuthClientCredentials:
+		return NewClientCredentials(types.MakeHTTPClient(conf.OAuth.TLSConfig, tokenConnections, 0), conf.OAuth)
+	case config.GatewayAuthBasic:
+		if len(conf.BasicAuthSecretPath) > 0 {
+			return NewBasicAuthFile(fs, conf.BasicAuthSecretPath, conf.BasicAuth)
+		}
+		return NewBasicAuth(conf.BasicAuth)
+	default:
+		return nil
+	}
+}
+
+// BasicAuth authenticates with the basic auth credentials of the gateway
+type BasicAuth struct {
+	header string
+}
+
+// NewBasicAuth creates a new provider for the credentials, it returns nil if there are no credentials
+func NewBasicAuth(credentials *auth.BasicAuthCredentials) AuthProvider {
+	if credentials == nil {
+		return nil
+	}
+
+	return &BasicAuth{header: encodeBasicAuth(credentials.User, credentials.Password)}
+}
+
+// Authorization returns the encoded credentials
+func (b *BasicAuth) Authorization(ctx context.Context) (string, error) {
+	return b.header, nil
+}
+
+// Names of the files within the secret mount path holding the basic auth credentials of the gateway
+const (
+	basicAuthUserFile     = "basic-auth-user"
+	basicAuthPasswordFile = "basic-auth-password"
+)
+
+// BasicAuthFile authenticates with the basic auth credentials stored in the secret mount path. The credentials can be
+// re-read at any time, e.g. after they got rotated, and are swapped atomically so in-flight requests are not affected.
+type BasicAuthFile struct {
+	fs   afero.Fs
+	path string
+
+	lock   sync.Mutex
+	header atomic.Value
+}
+
+// NewBasicAuthFile creates a new provider for the credentials stored in the secret mount path, the initial credentials
+// are the ones read during startup.
+func NewBasicAuthFile(fs afero.Fs, path string, initial *auth.BasicAuthCredentials) *BasicAuthFile {
+	provider := &BasicAuthFile{fs: fs, path: path}
+	provider.header.Store("")
+
+	if initial != nil {
+		provider.header.Store(encodeBasicAuth(initial.User, initial.Password))
+	}
+
+	return provider
+}
+
+// Authorization returns the encoded credentials, reading them from the secret mount path if they are not known yet
+func (b *BasicAuthFile) Authorization(ctx context.Context) (string, error) {
+	if header := b.header.Load().(string); len(header) > 0 {
+		return header, nil
+	}
+
+	if _, err := b.Reload(); err != nil {
+		return "", err
+	}
+
+	return b.header.Load().(string), nil
+}
+
+// Reload reads the credentials from the secret mount path again and swaps them if they changed. On failure, e.g. while
+// the secret is only partially written, the current credentials are kept.
+func (b *BasicAuthFile) Reload() (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	user, err := b.read(basicAuthUserFile)
+	if err != nil {
+		return false, err
+	}
+
+	password, err := b.read(basicAuthPasswordFile)
+	if err != nil {
+		return false, err
+	}
+
+	header := encodeBasicAuth(user, password)
+	if header == b.header.Load().(string) {
+		return false, nil
+	}
+
+	b.header.Store(header)
+	return true, nil
+}
+
+// Watch re-reads the credentials in the provided interval until the context is done, an interval of 0 disables watching
+func (b *BasicAuthFile) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := b.Reload()
+			if err != nil {
+				log.Printf("Received %s while reading OpenFaaS credentials from %s, will keep the current ones", err, b.path)
+			} else if changed {
+				log.Printf("Detected rotated OpenFaaS credentials at %s", b.path)
+			}
+		case <-ctx.Done():
+			log.Println("Received done via context will stop watching OpenFaaS credentials")
+			return
+		}
+	}
+}
+
+func (b *BasicAuthFile) read(name string) (string, error) {
+	file := path.Join(b.path, name)
+
+	content, err := afero.ReadFile(b.fs, file)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to load %s", file)
+	}
+
+	value := strings.TrimSpace(string(content))
+	if len(value) == 0 {
+		return "", errors.New(fmt.Sprintf("%s is empty", file))
+	}
+
+	return value, nil
+}
+
+func encodeBasicAuth(user string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// tokenFileRefresh is the interval after which the token file is read again, so rotated tokens are picked up
+const tokenFileRefresh = time.Minute
+
+// BearerTokenFile authenticates with a static bearer token read from a file, like a projected service account token
+type BearerTokenFile struct {
+	fs   afero.Fs
+	path string
+
+	lock   sync.Mutex
+	token  string
+	readAt time.Time
+}
+
+// NewBearerTokenFile creates a new provider for the token stored at the path
+func NewBearerTokenFile(fs afero.Fs, path string) AuthProvider {
+	return &BearerTokenFile{fs: fs, path: path}
+}
+
+// Authorization returns the token of the file, which is read again once a minute
+func (b *BearerTokenFile) Authorization(ctx context.Context) (string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.token) == 0 || time.Since(b.readAt) >= tokenFileRefresh {
+		if _, err := b.read(); err != nil {
+			return "", err
+		}
+	}
+
+	return "Bearer " + b.token, nil
+}
+
+// Reload reads the token file again, reporting whether the token changed
+func (b *BearerTokenFile) Reload() (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.read()
+}
+
+func (b *BearerTokenFile) read() (bool, error) {
+	content, err := afero.ReadFile(b.fs, b.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to read bearer token from %s", b.path)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if len(token) == 0 {
+		return false, errors.New(fmt.Sprintf("bearer token file %s is empty", b.path))
+	}
+
+	changed := token != b.token
+	b.token = token
+	b.readAt = time.Now()
+
+	return changed, nil
+}
+
+// Tokens without an expiry are cached for defaultTokenLifetime, tokens are refreshed a tenth of their lifetime
+// before they expire, but at most maxTokenRefreshMargin early.
+const (
+	defaultTokenLifetime  = 5 * time.Minute
+	maxTokenRefreshMargin = time.Minute
+)
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// tokenTimeout limits requests for access tokens, as the http client leaves timeouts to the individual requests
+const tokenTimeout = 30 * time.Second
+
+// tokenConnections limits the connections towards the token endpoint, as tokens are requested one at a time
+const tokenConnections = 2
+
+// ClientCredentials authenticates with an access token obtained via the OAuth2 client credentials flow, like issued by
+// an OIDC provider. The token is cached and refreshed shortly before it expires, while the cached one is still served.
+type ClientCredentials struct {
+	client *fasthttp.Client
+	conf   config.OAuthConfig
+
+	lock      sync.Mutex
+	token     string
+	refreshAt time.Time
+	expiry    time.Time
+	pending   *tokenRefresh
+}
+
+// tokenRefresh is a request for a new access token, which is shared by every caller waiting for a token meanwhile
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewClientCredentials creates a new provider requesting tokens from the token url of the config using the client
+func NewClientCredentials(client *fasthttp.Client, conf config.OAuthConfig) AuthProvider {
+	return &ClientCredentials{client: client, conf: conf}
+}
+
+// Authorization returns the cached access token. Once it is about to expire a new one is requested in the background,
+// while the cached one is served until it actually expired. Without a valid token it waits for the new one, unless the
+// context ends before.
+func (c *ClientCredentials) Authorization(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	now := time.Now()
+	if len(c.token) > 0 && now.Before(c.expiry) {
+		if !now.Before(c.refreshAt) {
+			c.refresh()
+		}
+
+		token := c.token
+		c.lock.Unlock()
+		return "Bearer " + token, nil
+	}
+
+	refresh := c.refresh()
+	c.lock.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "unable to obtain access token")
+	}
+
+	if refresh.err != nil {
+		return "", refresh.err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return "Bearer " + c.token, nil
+}
+
+// Reload requests a new access token, as the cached one got rejected before it expired. Until the new one is obtained,
+// callers wait for it instead of being served the rejected one.
+func (c *ClientCredentials) Reload() (bool, error) {
+	c.lock.Lock()
+	previous := c.token
+	c.expiry = time.Time{}
+	refresh := c.refresh()
+	c.lock.Unlock()
+
+	<-refresh.done
+	if refresh.err != nil {
+		return false, refresh.err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.token != previous, nil
+}
+
+// refresh starts requesting a new access token unless a request is already pending, callers have to hold the lock.
+// The request is not bound to the context of any caller, as every caller waiting meanwhile shares it.
+func (c *ClientCredentials) refresh() *tokenRefresh {
+	if c.pending != nil {
+		return c.pending
+	}
+
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	c.pending = refresh
+
+	go func() {
+		token, lifetime, err := c.request()
+
+		c.lock.Lock()
+		if err == nil {
+			margin := lifetime / 10
+			if margin > maxTokenRefreshMargin {
+				margin = maxTokenRefreshMargin
+			}
+
+			now := time.Now()
+			c.token = token
+			c.refreshAt = now.Add(lifetime - margin)
+			c.expiry = now.Add(lifetime)
+		} else if len(c.token) > 0 && time.Now().Before(c.expiry) {
+			log.Printf("Received %s while refreshing access token, will keep the current one", err)
+		}
+		c.pending = nil
+		refresh.err = err
+		c.lock.Unlock()
+
+		close(refresh.done)
+	}()
+
+	return refresh
+}
+
+// request obtains a new access token from the token endpoint together with its lifetime
+func (c *ClientCredentials) request() (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(c.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.conf.Scopes, " "))
+	}
+	if len(c.conf.Audience) > 0 {
+		form.Set("audience", c.conf.Audience)
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(c.conf.TokenURL)
+	req.SetBodyString(form.Encode())
+
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/x-www-form-urlencoded")
+	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
+	credentials := url.QueryEscape(c.conf.ClientID) + ":" + url.QueryEscape(c.conf.ClientSecret)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+
+	err := c.client.DoDeadline(req, resp, time.Now().Add(tokenTimeout))
+	if err != nil {
+		return "", 0, errors.Wrap(err, "unable to obtain access token")
+	}
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return "", 0, errors.New(fmt.Sprintf("Received unexpected Status Code %d while obtaining access token", resp.StatusCode()))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(resp.Body(), &token); err != nil || len(token.AccessToken) == 0 {
+		return "", 0, errors.New("received invalid access token response")
+	}
+
+	if len(token.TokenType) > 0 && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", 0, errors.New("received access token of unsupported type " + strconv.Quote(token.TokenType))
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+
+	return token.AccessToken, lifetime, nil
+}
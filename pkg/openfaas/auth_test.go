@@ -0,0 +1,318 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package openfaas
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Templum/rabbitmq-connector/pkg/config"
+	"github.com/openfaas/faas-provider/auth"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthProvider(t *testing.T) {
+	t.Run("Should not authenticate by default", func(t *testing.T) {
+		assert.Nil(t, NewAuthProvider(afero.NewMemMapFs(), &config.Controller{GatewayAuth: config.GatewayAuthNone}))
+	})
+
+	t.Run("Should create provider for configured auth", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+
+		assert.IsType(t, &BasicAuth{}, NewAuthProvider(fs, &config.Controller{GatewayAuth: config.GatewayAuthBasic, BasicAuth: &auth.BasicAuthCredentials{}}))
+		assert.IsType(t, &BasicAuthFile{}, NewAuthProvider(fs, &config.Controller{GatewayAuth: config.GatewayAuthBasic, BasicAuth: &auth.BasicAuthCredentials{}, BasicAuthSecretPath: "/var/secrets"}))
+		assert.IsType(t, &BearerTokenFile{}, NewAuthProvider(fs, &config.Controller{GatewayAuth: config.GatewayAuthBearer}))
+		assert.IsType(t, &ClientCredentials{}, NewAuthProvider(fs, &config.Controller{GatewayAuth: config.GatewayAuthClientCredentials}))
+	})
+
+	t.Run("Should request tokens with the TLS config of the token endpoint", func(t *testing.T) {
+		conf := &config.Controller{
+			GatewayAuth:      config.GatewayAuthClientCredentials,
+			GatewayTLSConfig: &tls.Config{ServerName: "gateway"},
+			OAuth:            config.OAuthConfig{TLSConfig: &tls.Config{ServerName: "idp"}},
+		}
+
+		provider := NewAuthProvider(afero.NewMemMapFs(), conf).(*ClientCredentials)
+
+		assert.Same(t, conf.OAuth.TLSConfig, provider.client.TLSConfig, "Should not reuse the TLS config of the gateway")
+	})
+}
+
+func TestBasicAuth_Authorization(t *testing.T) {
+	t.Run("Should encode credentials", func(t *testing.T) {
+		header, err := NewBasicAuth(&auth.BasicAuthCredentials{User: "admin", Password: "secret"}).Authorization(context.Background())
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Basic YWRtaW46c2VjcmV0", header)
+	})
+
+	t.Run("Should not create provider without credentials", func(t *testing.T) {
+		assert.Nil(t, NewBasicAuth(nil))
+	})
+}
+
+func TestBasicAuthFile_Reload(t *testing.T) {
+	writeCredentials := func(fs afero.Fs, user string, password string) {
+		_ = afero.WriteFile(fs, "/var/secrets/basic-auth-user", []byte(user+"\n"), 0600)
+		_ = afero.WriteFile(fs, "/var/secrets/basic-auth-password", []byte(password+"\n"), 0600)
+	}
+
+	t.Run("Should use initial credentials", func(t *testing.T) {
+		target := NewBasicAuthFile(afero.NewMemMapFs(), "/var/secrets", &auth.BasicAuthCredentials{User: "admin", Password: "secret"})
+
+		header, err := target.Authorization(context.Background())
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Basic YWRtaW46c2VjcmV0", header)
+	})
+
+	t.Run("Should read credentials if there are no initial ones", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writeCredentials(fs, "admin", "secret")
+
+		header, err := NewBasicAuthFile(fs, "/var/secrets", nil).Authorization(context.Background())
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Basic YWRtaW46c2VjcmV0", header)
+	})
+
+	t.Run("Should swap rotated credentials", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writeCredentials(fs, "admin", "rotated")
+		target := NewBasicAuthFile(fs, "/var/secrets", &auth.BasicAuthCredentials{User: "admin", Password: "secret"})
+
+		changed, err := target.Reload()
+		assert.NoError(t, err, "should not throw")
+		assert.True(t, changed, "should report rotated credentials")
+
+		header, _ := target.Authorization(context.Background())
+		assert.Equal(t, "Basic YWRtaW46cm90YXRlZA==", header)
+
+		changed, err = target.Reload()
+		assert.NoError(t, err, "should not throw")
+		assert.False(t, changed, "should not report unchanged credentials")
+	})
+
+	t.Run("Should keep current credentials if secret can not be read", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "/var/secrets/basic-auth-user", []byte("admin"), 0600)
+		target := NewBasicAuthFile(fs, "/var/secrets", &auth.BasicAuthCredentials{User: "admin", Password: "secret"})
+
+		changed, err := target.Reload()
+		assert.Error(t, err, "should throw")
+		assert.False(t, changed)
+
+		header, _ := target.Authorization(context.Background())
+		assert.Equal(t, "Basic YWRtaW46c2VjcmV0", header)
+	})
+
+	t.Run("Should pick up rotated credentials while watching", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		writeCredentials(fs, "admin", "secret")
+		target := NewBasicAuthFile(fs, "/var/secrets", nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go target.Watch(ctx, 10*time.Millisecond)
+
+		writeCredentials(fs, "admin", "rotated")
+		assert.Eventually(t, func() bool {
+			header, _ := target.Authorization(context.Background())
+			return header == "Basic YWRtaW46cm90YXRlZA=="
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestBearerTokenFile_Authorization(t *testing.T) {
+	t.Run("Should read token from file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "token", []byte("abc.def.ghi\n"), 0600)
+
+		header, err := NewBearerTokenFile(fs, "token").Authorization(context.Background())
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Bearer abc.def.ghi", header)
+	})
+
+	t.Run("Should pick up rotated token once refresh is due", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "token", []byte("initial"), 0600)
+		target := NewBearerTokenFile(fs, "token").(*BearerTokenFile)
+
+		_, _ = target.Authorization(context.Background())
+		_ = afero.WriteFile(fs, "token", []byte("rotated"), 0600)
+
+		header, _ := target.Authorization(context.Background())
+		assert.Equal(t, "Bearer initial", header, "should use cached token")
+
+		target.readAt = time.Now().Add(-tokenFileRefresh)
+		header, _ = target.Authorization(context.Background())
+		assert.Equal(t, "Bearer rotated", header, "should use rotated token")
+	})
+
+	t.Run("Should return error for missing or empty file", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "empty", []byte(" \n"), 0600)
+
+		_, err := NewBearerTokenFile(fs, "missing").Authorization(context.Background())
+		assert.Error(t, err, "should throw")
+
+		_, err = NewBearerTokenFile(fs, "empty").Authorization(context.Background())
+		assert.EqualError(t, err, "bearer token file empty is empty")
+	})
+}
+
+func TestClientCredentials_Authorization(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_ = r.ParseForm()
+
+		user, pass, _ := r.BasicAuth()
+		if user != "connector" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "openfaas invoke" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, atomic.LoadInt32(&requests))
+	}))
+	defer server.Close()
+
+	conf := config.OAuthConfig{TokenURL: server.URL, ClientID: "connector", ClientSecret: "secret", Scopes: []string{"openfaas", "invoke"}}
+
+	t.Run("Should obtain and cache access token", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		target := NewClientCredentials(CreateClient(nil), conf).(*ClientCredentials)
+
+		first, err := target.Authorization(context.Background())
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Bearer token-1", first)
+
+		second, _ := target.Authorization(context.Background())
+		assert.Equal(t, first, second, "should use cached token")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "should only request one token")
+
+		assert.WithinDuration(t, time.Now().Add(59*time.Minute), target.refreshAt, time.Minute, "should refresh a minute before expiry")
+		assert.WithinDuration(t, time.Now().Add(time.Hour), target.expiry, time.Minute, "should track expiry")
+	})
+
+	t.Run("Should serve cached token while refreshing it before it expires", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		target := NewClientCredentials(CreateClient(nil), conf).(*ClientCredentials)
+
+		_, _ = target.Authorization(context.Background())
+		target.lock.Lock()
+		target.refreshAt = time.Now()
+		target.lock.Unlock()
+
+		header, err := target.Authorization(context.Background())
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Bearer token-1", header, "should serve cached token")
+
+		assert.Eventually(t, func() bool {
+			header, _ := target.Authorization(context.Background())
+			return header == "Bearer token-2"
+		}, time.Second, 10*time.Millisecond, "should serve refreshed token")
+	})
+
+	t.Run("Should wait for a new token once the cached one expired", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		target := NewClientCredentials(CreateClient(nil), conf).(*ClientCredentials)
+
+		_, _ = target.Authorization(context.Background())
+		target.lock.Lock()
+		target.expiry = time.Now()
+		target.lock.Unlock()
+
+		header, err := target.Authorization(context.Background())
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, "Bearer token-2", header)
+	})
+
+	t.Run("Should request a new token on reload", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		target := NewClientCredentials(CreateClient(nil), conf).(*ClientCredentials)
+
+		_, _ = target.Authorization(context.Background())
+		changed, err := target.Reload()
+
+		assert.NoError(t, err, "should not throw")
+		assert.True(t, changed, "should report new token")
+		header, _ := target.Authorization(context.Background())
+		assert.Equal(t, "Bearer token-2", header)
+	})
+
+	t.Run("Should return error if token is refused", func(t *testing.T) {
+		invalid := conf
+		invalid.ClientSecret = "invalid"
+
+		_, err := NewClientCredentials(CreateClient(nil), invalid).Authorization(context.Background())
+
+		assert.EqualError(t, err, "Received unexpected Status Code 401 while obtaining access token")
+	})
+}
+
+func TestClientCredentials_SharedRefresh(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	target := NewClientCredentials(CreateClient(nil), config.OAuthConfig{TokenURL: server.URL}).(*ClientCredentials)
+
+	t.Run("Should stop waiting once the context ended", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := target.Authorization(ctx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Should share one request between every caller waiting", func(t *testing.T) {
+		var wg sync.WaitGroup
+		headers := make(chan string, 5)
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				header, _ := target.Authorization(context.Background())
+				headers <- header
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(headers)
+
+		for header := range headers {
+			assert.Equal(t, "Bearer token", header)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "should only request one token")
+	})
+}
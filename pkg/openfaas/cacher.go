@@ -9,7 +9,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	types2 "github.com/Templum/rabbitmq-connector/pkg/types"
@@ -25,9 +27,21 @@ import (
 // Cache with all of the deployed OpenFaaS Functions across
 // all namespaces
 type Controller struct {
-	conf   *config.Controller
-	client FunctionCrawler
-	cache  TopicMap
+	conf     *config.Controller
+	client   FunctionCrawler
+	cache    TopicMap
+	listener BindingListener
+
+	lock        sync.Mutex
+	pausedUntil time.Time
+	timeouts    map[string]time.Duration
+	retries     map[string]types2.RetryPolicy
+}
+
+// BindingListener gets notified with the bindings derived from the function annotations after every cache refresh.
+// The bindings map exchange names to the topics functions are subscribed to.
+type BindingListener interface {
+	SyncBindings(bindings map[string][]string)
 }
 
 // NewController returns a new instance
@@ -39,6 +53,12 @@ func NewController(conf *config.Controller, client FunctionCrawler, cache TopicM
 	}
 }
 
+// WithBindingListener registers a listener that is notified about the derived bindings, it has to be called before Start
+func (c *Controller) WithBindingListener(listener BindingListener) *Controller {
+	c.listener = listener
+	return c
+}
+
 // Start setups the cache and starts continuous caching
 func (c *Controller) Start(ctx context.Context) {
 	hasNamespaceSupport, _ := c.client.HasNamespaceSupport(ctx)
@@ -49,19 +69,167 @@ func (c *Controller) Start(ctx context.Context) {
 	go c.refresh(ctx, timer, hasNamespaceSupport)
 }
 
-// Invoke triggers a call to all functions registered to the specified topic. It will abort invocation in case it encounters an error
-func (c *Controller) Invoke(topic string, invocation *types2.OpenFaaSInvocation) error {
+// Invoke triggers a call to all functions registered to the specified topic. Failed functions are retried according to the
+// retry policy, afterwards it will abort invocation in case it encounters an error, which includes the context being cancelled.
+// The result contains every function invoked so far.
+func (c *Controller) Invoke(ctx context.Context, topic string, invocation *types2.OpenFaaSInvocation) (types2.InvocationResult, error) {
 	functions := c.cache.GetCachedValues(topic)
+	result := types2.InvocationResult{Topic: topic}
 
 	for _, fn := range functions {
-		_, err := c.client.InvokeSync(context.Background(), fn, invocation)
-		if err != nil {
-			log.Printf("Invocation for topic %s failed due to err %s", topic, err)
-			return err
+		outcome := c.invoke(ctx, fn, invocation)
+		result.Functions = append(result.Functions, outcome)
+
+		if outcome.Err != nil {
+			log.Printf("Invocation for topic %s failed on function %s after %s and %d attempt(s) due to err %s", topic, fn, outcome.Duration.Round(time.Millisecond), outcome.Attempts, outcome.Err)
+			return result, outcome.Err
 		}
 	}
 	log.Printf("Invocation for topic %s finished on %d function(s)", topic, len(functions))
-	return nil
+	return result, nil
+}
+
+// invoke calls the function, if OpenFaaS refuses the credentials invocations are paused for all topics until the
+// credentials got re-read, afterwards the function is called once more. If the credentials are still refused, the
+// result is returned so the delivery is settled by the disposition of the topic. A pause of 0 disables this. Other
+// failures are retried according to the retry policy.
+func (c *Controller) invoke(ctx context.Context, fn string, invocation *types2.OpenFaaSInvocation) types2.FunctionResult {
+	start := time.Now()
+	attempts := 0
+	paused := false
+
+	for {
+		if err := c.waitWhilePaused(ctx); err != nil {
+			return types2.FunctionResult{Function: fn, Attempts: attempts, Class: types2.Classify(0, err), Err: err}
+		}
+
+		callCtx, cancel := c.deadline(ctx, fn, invocation)
+		result, err := c.client.InvokeSync(callCtx, fn, invocation)
+		cancel()
+
+		result.Function = fn
+		result.Err = err
+		result.Class = types2.Classify(result.StatusCode, err)
+
+		if result.Class == types2.ClassUnauthorized && !paused && c.conf != nil && c.conf.GatewayAuthPause > 0 {
+			paused = true
+			c.pause()
+			continue
+		}
+
+		attempts++
+		result.Attempts = attempts
+		if result.Err == nil || !c.retry(ctx, result, start, invocation) {
+			return result
+		}
+	}
+}
+
+// retry waits before the failed function is invoked again, it reports false if the function should not be retried
+// or the context ended or the consumer started draining while waiting. Retries that would start after the deadline of
+// the message are not attempted.
+func (c *Controller) retry(ctx context.Context, result types2.FunctionResult, start time.Time, invocation *types2.OpenFaaSInvocation) bool {
+	if c.conf == nil {
+		return false
+	}
+
+	policy := c.retryPolicy(result.Function)
+	if !policy.Retryable(result.Class) || policy.Exhausted(result.Attempts) {
+		return false
+	}
+
+	delay := policy.Delay(result.Attempts)
+	if !policy.Fits(start, delay) {
+		log.Printf("Retry budget of %s for function %s is used up after %d attempt(s)", policy.Budget, result.Function, result.Attempts)
+		return false
+	}
+
+	if !invocation.Deadline.IsZero() && time.Now().Add(delay).After(invocation.Deadline) {
+		log.Printf("Message for function %s expires before it could be retried after %d attempt(s)", result.Function, result.Attempts)
+		return false
+	}
+
+	log.Printf("Invocation of function %s failed with %s in attempt %d/%d, will retry in %s", result.Function, result.Class, result.Attempts, policy.MaxAttempts, delay.Round(time.Millisecond))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-types2.Drained(ctx):
+		return false
+	}
+}
+
+// retryPolicy returns the retry policy of the function, which falls back to the one of the connector
+func (c *Controller) retryPolicy(fn string) types2.RetryPolicy {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if policy, exists := c.retries[fn]; exists {
+		return policy
+	}
+	return c.conf.Retry
+}
+
+// deadline limits the invocation to the timeout of the function, which falls back to the one of the topic and then
+// to the one of the connector. A deadline of the message is kept if it is earlier.
+func (c *Controller) deadline(ctx context.Context, fn string, invocation *types2.OpenFaaSInvocation) (context.Context, context.CancelFunc) {
+	c.lock.Lock()
+	timeout := c.timeouts[fn]
+	c.lock.Unlock()
+
+	if timeout <= 0 {
+		timeout = invocation.Timeout
+	}
+	if timeout <= 0 && c.conf != nil {
+		timeout = c.conf.RequestTimeout
+	}
+
+	deadline := invocation.Deadline
+	if timeout > 0 && (deadline.IsZero() || time.Now().Add(timeout).Before(deadline)) {
+		deadline = time.Now().Add(timeout)
+	}
+
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+func (c *Controller) pause() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if until := time.Now().Add(c.conf.GatewayAuthPause); until.After(c.pausedUntil) {
+		log.Printf("OpenFaaS refused the credentials, will pause invocations for %s before retrying", c.conf.GatewayAuthPause)
+		c.pausedUntil = until
+	}
+}
+
+func (c *Controller) waitWhilePaused(ctx context.Context) error {
+	c.lock.Lock()
+	remaining := time.Until(c.pausedUntil)
+	c.lock.Unlock()
+
+	if remaining <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-types2.Drained(ctx):
+		return types2.ErrDraining
+	}
 }
 
 func (c *Controller) refresh(ctx context.Context, ticker *time.Ticker, hasNamespaceSupport bool) {
@@ -82,6 +250,7 @@ func (c *Controller) refreshTick(ctx context.Context, hasNamespaceSupport bool)
 	builder := NewFunctionMapBuilder()
 	var namespaces []string
 	var err error
+	complete := true
 
 	if hasNamespaceSupport {
 		log.Println("Crawling namespaces for functions")
@@ -89,38 +258,90 @@ func (c *Controller) refreshTick(ctx context.Context, hasNamespaceSupport bool)
 		if err != nil {
 			log.Printf("Received the following error during fetching namespaces %s", err)
 			namespaces = []string{}
+			complete = false
 		}
 	} else {
 		namespaces = []string{""}
 	}
 
 	log.Println("Crawling for functions")
-	c.crawlFunctions(ctx, namespaces, builder)
+	crawled := c.crawlFunctions(ctx, namespaces, builder)
 
 	log.Println("Crawling finished will now refresh the cache")
 	c.cache.Refresh(builder.Build())
+
+	// A hiccup of the gateway must not remove the bindings of every function, so they are only replaced by a complete crawl
+	if !complete || !crawled.complete {
+		log.Println("Crawling was incomplete, will keep the previous bindings, timeouts and retry policies")
+		return
+	}
+
+	c.lock.Lock()
+	c.timeouts = crawled.timeouts
+	c.retries = crawled.retries
+	c.lock.Unlock()
+
+	if c.listener != nil {
+		c.listener.SyncBindings(crawled.bindings)
+	}
+}
+
+// crawlResult contains what was derived from the annotations of the functions. It is incomplete if the functions
+// of a namespace could not be fetched.
+type crawlResult struct {
+	bindings map[string][]string
+	timeouts map[string]time.Duration
+	retries  map[string]types2.RetryPolicy
+	complete bool
 }
 
-func (c *Controller) crawlFunctions(ctx context.Context, namespaces []string, builder TopicMapBuilder) {
+// crawlFunctions appends every function to the topics it listens on and returns the topics per exchange as well as the
+// timeouts and retry policies of the functions
+func (c *Controller) crawlFunctions(ctx context.Context, namespaces []string, builder TopicMapBuilder) crawlResult {
+	crawled := crawlResult{
+		bindings: make(map[string][]string),
+		timeouts: make(map[string]time.Duration),
+		retries:  make(map[string]types2.RetryPolicy),
+		complete: true,
+	}
+
 	for _, ns := range namespaces {
 		found, err := c.client.GetFunctions(ctx, ns)
 		if err != nil {
 			log.Printf("Received %s while fetching functions on namespace %s", err, ns)
 			found = []types.FunctionStatus{}
+			crawled.complete = false
 		}
 
 		for _, fn := range found {
 			topics := c.extractTopicsFromAnnotations(fn)
+			exchange := c.extractExchangeFromAnnotations(fn)
+
+			name := fn.Name
+			if len(ns) > 0 {
+				name = fmt.Sprintf("%s.%s", fn.Name, ns) // Include Namespace to call the correct function
+			}
+
+			if timeout := c.extractTimeoutFromAnnotations(fn); timeout > 0 {
+				crawled.timeouts[name] = timeout
+			}
+
+			if policy, overridden := c.extractRetryFromAnnotations(fn); overridden {
+				crawled.retries[name] = policy
+			}
 
 			for _, topic := range topics {
-				if len(ns) > 0 {
-					builder.Append(topic, fmt.Sprintf("%s.%s", fn.Name, ns)) // Include Namespace to call the correct function
-				} else {
-					builder.Append(topic, fn.Name)
+				builder.Append(topic, name)
+
+				key := strings.TrimSpace(topic)
+				if len(key) > 0 && !contains(crawled.bindings[exchange], key) {
+					crawled.bindings[exchange] = append(crawled.bindings[exchange], key)
 				}
 			}
 		}
 	}
+
+	return crawled
 }
 
 func (c *Controller) extractTopicsFromAnnotations(fn types.FunctionStatus) []string {
@@ -135,3 +356,113 @@ func (c *Controller) extractTopicsFromAnnotations(fn types.FunctionStatus) []str
 
 	return topics
 }
+
+func (c *Controller) extractExchangeFromAnnotations(fn types.FunctionStatus) string {
+	if fn.Annotations != nil {
+		annotations := *fn.Annotations
+		if exchange, exist := annotations["exchange"]; exist && len(strings.TrimSpace(exchange)) > 0 {
+			return strings.TrimSpace(exchange)
+		}
+	}
+
+	return c.conf.DynamicExchange
+}
+
+func (c *Controller) extractTimeoutFromAnnotations(fn types.FunctionStatus) time.Duration {
+	if fn.Annotations == nil {
+		return 0
+	}
+
+	raw, exist := (*fn.Annotations)["timeout"]
+	if !exist {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil || timeout <= 0 {
+		log.Printf("Provided timeout %s of function %s is not a valid Duration, like 10s or 1m. Will ignore it", raw, fn.Name)
+		return 0
+	}
+
+	return timeout
+}
+
+// extractRetryFromAnnotations overrides the retry policy of the connector with the retry-max-attempts, retry-base-delay,
+// retry-max-delay, retry-budget and retry-classes annotations. It reports false if the function has none of them.
+func (c *Controller) extractRetryFromAnnotations(fn types.FunctionStatus) (types2.RetryPolicy, bool) {
+	policy := c.conf.Retry
+	if fn.Annotations == nil {
+		return policy, false
+	}
+
+	annotations := *fn.Annotations
+	overridden := false
+
+	if raw, exist := annotations["retry-max-attempts"]; exist {
+		if attempts, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && attempts >= 1 {
+			policy.MaxAttempts = attempts
+			overridden = true
+		} else {
+			log.Printf("Provided retry-max-attempts %s of function %s is not a valid positive number. Will ignore it", raw, fn.Name)
+		}
+	}
+
+	// A delay of 0 would retry right away in a tight loop, while a budget of 0 only disables the budget
+	durations := []struct {
+		annotation string
+		target     *time.Duration
+		positive   bool
+	}{
+		{"retry-base-delay", &policy.BaseDelay, true},
+		{"retry-max-delay", &policy.MaxDelay, true},
+		{"retry-budget", &policy.Budget, false},
+	}
+	for _, duration := range durations {
+		raw, exist := annotations[duration.annotation]
+		if !exist {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || parsed < 0 || (duration.positive && parsed == 0) {
+			log.Printf("Provided %s %s of function %s is not a valid Duration, like 200ms or 1s. Will ignore it", duration.annotation, raw, fn.Name)
+			continue
+		}
+		*duration.target = parsed
+		overridden = true
+	}
+
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+
+	if raw, exist := annotations["retry-classes"]; exist {
+		var classes []types2.ErrorClass
+		for _, name := range strings.Split(raw, ",") {
+			class := types2.ErrorClass(strings.ToLower(strings.TrimSpace(name)))
+			if len(class) == 0 {
+				continue
+			}
+
+			if !class.Known() {
+				log.Printf("Provided retry class %s of function %s is not known. Will ignore it", name, fn.Name)
+				continue
+			}
+			classes = append(classes, class)
+		}
+		policy.Classes = classes
+		overridden = true
+	}
+
+	return policy, overridden
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+
+	return false
+}
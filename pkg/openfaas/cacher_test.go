@@ -53,9 +53,9 @@ func (m *MockOpenFaaSClient) InvokeAsync(ctx context.Context, name string, invoc
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockOpenFaaSClient) InvokeSync(ctx context.Context, name string, invocation *types2.OpenFaaSInvocation) ([]byte, error) {
+func (m *MockOpenFaaSClient) InvokeSync(ctx context.Context, name string, invocation *types2.OpenFaaSInvocation) (types2.FunctionResult, error) {
 	args := m.Called(ctx, name, invocation)
-	return args.Get(0).([]byte), args.Error(1)
+	return args.Get(0).(types2.FunctionResult), args.Error(1)
 }
 
 func (m *MockOpenFaaSClient) HasNamespaceSupport(ctx context.Context) (bool, error) {
@@ -257,6 +257,75 @@ func TestCacher_Start_WithFailures(t *testing.T) {
 	})
 }
 
+type MockBindingListener struct {
+	mock.Mock
+}
+
+func (l *MockBindingListener) SyncBindings(bindings map[string][]string) {
+	l.Called(bindings)
+}
+
+func TestCacher_Start_WithBindingListener(t *testing.T) {
+	billing := map[string]string{"topic": "billing, transport"}
+	audit := map[string]string{"topic": "billing,audit", "exchange": "Audit"}
+
+	functions := []types.FunctionStatus{
+		{Name: "biller", Annotations: &billing},
+		{Name: "transporter", Annotations: &billing},
+		{Name: "auditor", Annotations: &audit},
+		{Name: "unrelated"},
+	}
+
+	clientMock := new(MockOpenFaaSClient)
+	clientMock.On("HasNamespaceSupport", mock.Anything).Return(false, nil)
+	clientMock.On("GetFunctions", mock.Anything).Return(functions, nil)
+
+	conf := &config.Controller{TopicRefreshTime: 1 * time.Minute, DynamicExchange: "amq.topic"}
+
+	t.Run("Should notify listener with bindings per exchange", func(t *testing.T) {
+		cacheMock := new(MockTopicMap)
+		listener := new(MockBindingListener)
+		listener.On("SyncBindings", map[string][]string{
+			"amq.topic": {"billing", "transport"},
+			"Audit":     {"billing", "audit"},
+		})
+
+		cacher := NewController(conf, clientMock, cacheMock).WithBindingListener(listener)
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+
+		cacher.Start(ctx)
+		listener.AssertExpectations(t)
+	})
+
+	t.Run("Should keep previous bindings and timeouts if functions could not be fetched", func(t *testing.T) {
+		failingMock := new(MockOpenFaaSClient)
+		failingMock.On("GetFunctions", "").Return([]types.FunctionStatus{}, errors.New("gateway unavailable"))
+
+		listener := new(MockBindingListener)
+		cacher := NewController(conf, failingMock, new(MockTopicMap)).WithBindingListener(listener)
+		cacher.timeouts = map[string]time.Duration{"biller": time.Minute}
+
+		cacher.refreshTick(context.TODO(), false)
+
+		listener.AssertNotCalled(t, "SyncBindings", mock.Anything)
+		assert.Equal(t, map[string]time.Duration{"biller": time.Minute}, cacher.timeouts)
+	})
+
+	t.Run("Should keep previous bindings if namespaces could not be fetched", func(t *testing.T) {
+		failingMock := new(MockOpenFaaSClient)
+		failingMock.On("GetNamespaces", mock.Anything).Return([]string{}, errors.New("gateway unavailable"))
+
+		listener := new(MockBindingListener)
+		cacher := NewController(conf, failingMock, new(MockTopicMap)).WithBindingListener(listener)
+
+		cacher.refreshTick(context.TODO(), true)
+
+		listener.AssertNotCalled(t, "SyncBindings", mock.Anything)
+	})
+}
+
 func TestCacher_Invoke(t *testing.T) {
 	cacheMock := new(MockTopicMap)
 	cacheMock.On("GetCachedValues", "Security").Return([]string{})
@@ -266,11 +335,11 @@ func TestCacher_Invoke(t *testing.T) {
 
 	t.Run("Should invoke all functions for specified Topic", func(t *testing.T) {
 		clientMock := new(MockOpenFaaSClient)
-		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return([]byte{}, nil)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, nil)
 
 		cacher := NewController(nil, clientMock, cacheMock)
 
-		err := cacher.Invoke(TOPIC, nil)
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
 
 		assert.NoError(t, err, "should not throw")
 		clientMock.AssertNumberOfCalls(t, "InvokeSync", 3)
@@ -279,26 +348,318 @@ func TestCacher_Invoke(t *testing.T) {
 
 	t.Run("Should abort invocation of functions on receiving first error further returning it", func(t *testing.T) {
 		clientMock := new(MockOpenFaaSClient)
-		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return([]byte{}, errors.New("failed"))
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, errors.New("failed"))
 
 		cacher := NewController(nil, clientMock, cacheMock)
 
-		err := cacher.Invoke(TOPIC, nil)
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
 
 		assert.Error(t, err, "failed")
 		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
 		clientMock.AssertExpectations(t)
 	})
 
+	t.Run("Should pause and retry if credentials are refused", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, types2.ErrUnauthorized).Once()
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, nil)
+
+		cacher := NewController(&config.Controller{GatewayAuthPause: 50 * time.Millisecond}, clientMock, cacheMock)
+
+		start := time.Now()
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.NoError(t, err, "should not throw")
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "should pause before retrying")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 4)
+	})
+
+	t.Run("Should return refused credentials if pausing is disabled", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, types2.ErrUnauthorized)
+
+		cacher := NewController(&config.Controller{}, clientMock, cacheMock)
+
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.ErrorIs(t, err, types2.ErrUnauthorized)
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	t.Run("Should limit invocations to the most specific timeout", func(t *testing.T) {
+		hasDeadline := func(timeout time.Duration) interface{} {
+			return mock.MatchedBy(func(ctx context.Context) bool {
+				deadline, ok := ctx.Deadline()
+				return ok && time.Until(deadline) > timeout-time.Second && time.Until(deadline) <= timeout
+			})
+		}
+
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", hasDeadline(time.Minute), "billing", mock.Anything).Return(types2.FunctionResult{}, nil)
+		clientMock.On("InvokeSync", hasDeadline(20*time.Second), "secret", mock.Anything).Return(types2.FunctionResult{}, nil)
+		clientMock.On("InvokeSync", hasDeadline(20*time.Second), "transport", mock.Anything).Return(types2.FunctionResult{}, nil)
+
+		cacher := NewController(&config.Controller{RequestTimeout: 30 * time.Second}, clientMock, cacheMock)
+		cacher.timeouts = map[string]time.Duration{"billing": time.Minute}
+
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{Timeout: 20 * time.Second})
+
+		assert.NoError(t, err, "should not throw")
+		clientMock.AssertExpectations(t)
+	})
+
+	t.Run("Should keep earlier deadline of the message", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.MatchedBy(func(ctx context.Context) bool {
+			deadline, ok := ctx.Deadline()
+			return ok && time.Until(deadline) <= 5*time.Second
+		}), mock.Anything, mock.Anything).Return(types2.FunctionResult{}, nil)
+
+		cacher := NewController(&config.Controller{RequestTimeout: 30 * time.Second}, clientMock, cacheMock)
+
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{Deadline: time.Now().Add(5 * time.Second)})
+
+		assert.NoError(t, err, "should not throw")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 3)
+	})
+
+	t.Run("Should return the result of every invoked function", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, "billing", mock.Anything).Return(types2.FunctionResult{StatusCode: 200, Body: []byte("billed")}, nil)
+		clientMock.On("InvokeSync", mock.Anything, "secret", mock.Anything).Return(types2.FunctionResult{StatusCode: 503}, errors.New("failed"))
+
+		cacher := NewController(nil, clientMock, cacheMock)
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "failed")
+		assert.Equal(t, TOPIC, result.Topic)
+		assert.Len(t, result.Functions, 2, "should not invoke functions after the failed one")
+		assert.Equal(t, "billing", result.Functions[0].Function)
+		assert.Equal(t, "billed", string(result.Functions[0].Body))
+		assert.Equal(t, types2.ClassNone, result.Functions[0].Class)
+		assert.Equal(t, "secret", result.Failed().Function)
+		assert.Equal(t, types2.ClassServerError, result.Failed().Class)
+	})
+
+	t.Run("Should stop waiting for paused invocations once the context is cancelled", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 401}, types2.ErrUnauthorized)
+
+		cacher := NewController(&config.Controller{GatewayAuthPause: time.Minute}, clientMock, cacheMock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		result, err := cacher.Invoke(ctx, TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, types2.ClassCanceled, result.Failed().Class)
+		assert.Less(t, time.Since(start), time.Second, "should not wait for the pause to end")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	t.Run("Should return refused credentials if they are still refused after the pause", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 401}, types2.ErrUnauthorized)
+
+		cacher := NewController(&config.Controller{GatewayAuthPause: 20 * time.Millisecond}, clientMock, cacheMock)
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.ErrorIs(t, err, types2.ErrUnauthorized)
+		assert.Equal(t, 401, result.Failed().StatusCode)
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 2)
+	})
+
+	t.Run("Should stop waiting for paused invocations once the consumer drains", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 401}, types2.ErrUnauthorized)
+
+		cacher := NewController(&config.Controller{GatewayAuthPause: time.Minute}, clientMock, cacheMock)
+
+		drained := make(chan struct{})
+		time.AfterFunc(50*time.Millisecond, func() { close(drained) })
+
+		start := time.Now()
+		result, err := cacher.Invoke(types2.WithDrain(context.Background(), drained), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.ErrorIs(t, err, types2.ErrDraining)
+		assert.Equal(t, types2.ClassCanceled, result.Failed().Class)
+		assert.Less(t, time.Since(start), time.Second, "should not wait for the pause to end")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	retrying := &config.Controller{Retry: types2.RetryPolicy{MaxAttempts: 3, BaseDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond,
+		Classes: []types2.ErrorClass{types2.ClassServerError, types2.ClassTransport}}}
+
+	t.Run("Should retry failed functions before giving up", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, "billing", mock.Anything).Return(types2.FunctionResult{StatusCode: 502}, errors.New("failed")).Twice()
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 200}, nil)
+
+		cacher := NewController(retrying, clientMock, cacheMock)
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, 3, result.Functions[0].Attempts)
+		assert.Equal(t, 1, result.Functions[1].Attempts)
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 5)
+	})
+
+	t.Run("Should return last failure once attempts are exhausted", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 503}, errors.New("failed"))
+
+		cacher := NewController(retrying, clientMock, cacheMock)
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "failed")
+		assert.Equal(t, 3, result.Failed().Attempts)
+		assert.Equal(t, types2.ClassServerError, result.Failed().Class)
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 3)
+	})
+
+	t.Run("Should not retry failures of other classes", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 400}, errors.New("refused"))
+
+		cacher := NewController(retrying, clientMock, cacheMock)
+
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "refused")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	t.Run("Should stop retrying once the budget is used up", func(t *testing.T) {
+		budgeted := *retrying
+		budgeted.Retry.MaxAttempts = 10
+		budgeted.Retry.Budget = 50 * time.Millisecond
+
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, errors.New("connection refused"))
+
+		cacher := NewController(&budgeted, clientMock, cacheMock)
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "connection refused")
+		assert.Less(t, result.Failed().Attempts, 10, "should not use up all attempts")
+		assert.GreaterOrEqual(t, result.Failed().Attempts, 2, "should retry within the budget")
+	})
+
+	t.Run("Should not retry after the deadline of the message", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 502}, errors.New("failed"))
+
+		cacher := NewController(retrying, clientMock, cacheMock)
+
+		_, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{Deadline: time.Now().Add(10 * time.Millisecond)})
+
+		assert.Error(t, err, "failed")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	t.Run("Should stop retrying once the context is cancelled", func(t *testing.T) {
+		slow := *retrying
+		slow.Retry.BaseDelay = time.Minute
+		slow.Retry.MaxDelay = time.Minute
+
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 502}, errors.New("failed"))
+
+		cacher := NewController(&slow, clientMock, cacheMock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := cacher.Invoke(ctx, TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "failed")
+		assert.Less(t, time.Since(start), time.Second, "should not wait for the delay")
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 1)
+	})
+
+	t.Run("Should prefer the retry policy of the function", func(t *testing.T) {
+		clientMock := new(MockOpenFaaSClient)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{StatusCode: 502}, errors.New("failed"))
+
+		cacher := NewController(&config.Controller{Retry: types2.RetryPolicy{MaxAttempts: 1}}, clientMock, cacheMock)
+		cacher.retries = map[string]types2.RetryPolicy{"billing": {MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond,
+			Classes: []types2.ErrorClass{types2.ClassServerError}}}
+
+		result, err := cacher.Invoke(context.Background(), TOPIC, &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "failed")
+		assert.Equal(t, 2, result.Failed().Attempts)
+		clientMock.AssertNumberOfCalls(t, "InvokeSync", 2)
+	})
+
 	t.Run("Should not invoke if there is no function for specified Topic", func(t *testing.T) {
 		clientMock := new(MockOpenFaaSClient)
-		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return([]byte{}, nil)
+		clientMock.On("InvokeSync", mock.Anything, mock.Anything, mock.Anything).Return(types2.FunctionResult{}, nil)
 
 		cacher := NewController(nil, clientMock, cacheMock)
 
-		err := cacher.Invoke("Security", nil)
+		_, err := cacher.Invoke(context.Background(), "Security", nil)
 
 		assert.NoError(t, err, "should not throw")
 		clientMock.AssertNotCalled(t, "InvokeSync")
 	})
 }
+
+func TestCacher_CrawlTimeouts(t *testing.T) {
+	timed := map[string]string{"topic": "billing", "timeout": "2m"}
+	invalid := map[string]string{"topic": "billing", "timeout": "soon"}
+
+	clientMock := new(MockOpenFaaSClient)
+	clientMock.On("GetFunctions", "faas").Return([]types.FunctionStatus{
+		{Name: "biller", Annotations: &timed},
+		{Name: "auditor", Annotations: &invalid},
+	}, nil)
+	clientMock.On("GetFunctions", "").Return([]types.FunctionStatus{{Name: "transporter", Annotations: &timed}}, nil)
+
+	cacher := NewController(&config.Controller{}, clientMock, new(MockTopicMap))
+
+	crawled := cacher.crawlFunctions(context.TODO(), []string{"faas", ""}, NewFunctionMapBuilder())
+
+	assert.True(t, crawled.complete)
+	assert.Equal(t, map[string]time.Duration{"biller.faas": 2 * time.Minute, "transporter": 2 * time.Minute}, crawled.timeouts)
+}
+
+func TestCacher_CrawlRetries(t *testing.T) {
+	global := types2.RetryPolicy{MaxAttempts: 1, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second, Budget: 30 * time.Second,
+		Classes: []types2.ErrorClass{types2.ClassTransport}}
+
+	overridden := map[string]string{"topic": "billing", "retry-max-attempts": "5", "retry-base-delay": "10s", "retry-classes": "server-error, unknown"}
+	invalid := map[string]string{"topic": "billing", "retry-max-attempts": "many", "retry-budget": "soon"}
+	zero := map[string]string{"topic": "billing", "retry-base-delay": "0s", "retry-max-delay": "0", "retry-budget": "-1s"}
+	unlimited := map[string]string{"topic": "billing", "retry-budget": "0s"}
+	plain := map[string]string{"topic": "billing"}
+
+	clientMock := new(MockOpenFaaSClient)
+	clientMock.On("GetFunctions", "").Return([]types.FunctionStatus{
+		{Name: "biller", Annotations: &overridden},
+		{Name: "auditor", Annotations: &invalid},
+		{Name: "looper", Annotations: &zero},
+		{Name: "spender", Annotations: &unlimited},
+		{Name: "transporter", Annotations: &plain},
+	}, nil)
+
+	cacher := NewController(&config.Controller{Retry: global}, clientMock, new(MockTopicMap))
+
+	crawled := cacher.crawlFunctions(context.TODO(), []string{""}, NewFunctionMapBuilder())
+
+	assert.Equal(t, map[string]types2.RetryPolicy{
+		"biller": {MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second, Budget: 30 * time.Second,
+			Classes: []types2.ErrorClass{types2.ClassServerError}},
+		"spender": {MaxAttempts: 1, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second, Budget: 0,
+			Classes: []types2.ErrorClass{types2.ClassTransport}},
+	}, crawled.retries, "Should only keep valid overrides and fall back to the connector for the rest")
+	assert.NotContains(t, crawled.retries, "looper", "Should reject delays that are not positive")
+}
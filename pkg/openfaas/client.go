@@ -7,22 +7,23 @@ package openfaas
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"strconv"
+	"time"
 
 	internal "github.com/Templum/rabbitmq-connector/pkg/types"
 	"github.com/valyala/fasthttp"
 
-	"github.com/openfaas/faas-provider/auth"
 	"github.com/openfaas/faas-provider/types"
 	"github.com/pkg/errors"
 )
 
 // Invoker defines interfaces that invoke deployed OpenFaaS Functions.
 type Invoker interface {
-	InvokeSync(ctx context.Context, name string, invocation *internal.OpenFaaSInvocation) ([]byte, error)
+	InvokeSync(ctx context.Context, name string, invocation *internal.OpenFaaSInvocation) (internal.FunctionResult, error)
 	InvokeAsync(ctx context.Context, name string, invocation *internal.OpenFaaSInvocation) (bool, error)
 }
 
@@ -44,25 +45,148 @@ type FunctionCrawler interface {
 	Invoker
 }
 
+// TimeoutHeader passes the remaining budget of an invocation in milliseconds to the function
+const TimeoutHeader = "X-Rabbitmq-Timeout-Ms"
+
 // Client is used for interacting with Open FaaS
 type Client struct {
-	client      *fasthttp.Client
-	credentials *auth.BasicAuthCredentials
-	url         string
+	client  *fasthttp.Client
+	auth    AuthProvider
+	url     string
+	timeout time.Duration
 }
 
 // NewClient creates a new instance of an OpenFaaS Client using
-// the provided information
-func NewClient(client *fasthttp.Client, creds *auth.BasicAuthCredentials, gatewayURL string) *Client {
+// the provided information, the auth provider is optional
+func NewClient(client *fasthttp.Client, provider AuthProvider, gatewayURL string) *Client {
 	return &Client{
-		client:      client,
-		credentials: creds,
-		url:         gatewayURL,
+		client: client,
+		auth:   provider,
+		url:    gatewayURL,
+	}
+}
+
+// WithTimeout sets the timeout of requests whose context has no deadline, 0 waits without limit
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	c.timeout = timeout
+	return c
+}
+
+// do performs the request with the Authorization header of the auth provider. If OpenFaaS refuses the credentials,
+// they are re-read and the request is retried once if they got rotated in the meantime.
+func (c *Client) do(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := c.authorize(ctx, req); err != nil {
+		return err
+	}
+
+	if err := c.send(ctx, req, resp); err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != fasthttp.StatusUnauthorized || !c.reload() {
+		return nil
+	}
+
+	log.Println("OpenFaaS refused the credentials, will retry with the rotated ones")
+	if err := c.authorize(ctx, req); err != nil {
+		return err
+	}
+
+	resp.Reset()
+	return c.send(ctx, req, resp)
+}
+
+// send performs the request until the deadline of the context is reached, which falls back to the timeout of the client.
+// As fasthttp cannot abort requests, cancelling the context abandons a copy of the request, which completes in the background.
+func (c *Client) send(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	if ctx.Done() == nil {
+		return c.perform(ctx, req, resp)
+	}
+
+	pending := fasthttp.AcquireRequest()
+	result := fasthttp.AcquireResponse()
+	req.CopyTo(pending)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.perform(ctx, pending, result)
+	}()
+
+	release := func() {
+		fasthttp.ReleaseRequest(pending)
+		fasthttp.ReleaseResponse(result)
+	}
+
+	select {
+	case err := <-done:
+		result.CopyTo(resp)
+		release()
+		return err
+	case <-ctx.Done():
+		go func() {
+			<-done
+			release()
+		}()
+		return ctx.Err()
+	}
+}
+
+func (c *Client) perform(ctx context.Context, req *fasthttp.Request, resp *fasthttp.Response) error {
+	deadline, ok := ctx.Deadline()
+	if !ok && c.timeout <= 0 {
+		return c.client.Do(req, resp)
 	}
+	if !ok {
+		return c.client.DoDeadline(req, resp, time.Now().Add(c.timeout))
+	}
+
+	err := c.client.DoDeadline(req, resp, deadline)
+	if errors.Is(err, fasthttp.ErrTimeout) {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+// reload re-reads the credentials of the auth provider, reporting whether they changed
+func (c *Client) reload() bool {
+	reloader, ok := c.auth.(Reloader)
+	if !ok {
+		return false
+	}
+
+	changed, err := reloader.Reload()
+	if err != nil {
+		log.Printf("Received %s while re-reading OpenFaaS credentials", err)
+		return false
+	}
+
+	return changed
 }
 
-// InvokeSync calls a given function in a synchronous way waiting for the response using the provided payload while considering the provided context
-func (c *Client) InvokeSync(ctx context.Context, name string, invocation *internal.OpenFaaSInvocation) ([]byte, error) {
+// authorize sets the Authorization header supplied by the auth provider
+func (c *Client) authorize(ctx context.Context, req *fasthttp.Request) error {
+	if c.auth == nil {
+		return nil
+	}
+
+	header, err := c.auth.Authorization(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to authenticate towards OpenFaaS")
+	}
+
+	req.Header.Set("Authorization", header)
+	return nil
+}
+
+// InvokeSync calls a given function in a synchronous way waiting for the response using the provided payload while considering the provided context.
+// The call is aborted once the deadline of the context is reached, the remaining time is passed to the function via the TimeoutHeader.
+// The result contains the response of the function, if there was one, even if an error is returned. Failures are returned as InvocationError.
+func (c *Client) InvokeSync(ctx context.Context, name string, invocation *internal.OpenFaaSInvocation) (internal.FunctionResult, error) {
 	functionURL := fmt.Sprintf("%s/function/%s", c.url, name)
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
@@ -81,25 +205,34 @@ func (c *Client) InvokeSync(ctx context.Context, name string, invocation *intern
 	req.Header.Set("Content-Type", invocation.ContentType)
 	req.Header.Set("Content-Encoding", invocation.ContentEncoding)
 	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
-	if c.credentials != nil {
-		credentials := c.credentials.User + ":" + c.credentials.Password
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(TimeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
 	}
 
-	err := c.client.Do(req, resp)
+	start := time.Now()
+	err := c.do(ctx, req, resp)
+	result := internal.FunctionResult{Function: name, Duration: time.Since(start)}
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to invoke function %s", name)
+		return result, internal.NewInvocationError(name, 0, errors.Wrapf(err, "unable to invoke function %s", name))
 	}
 
-	switch resp.StatusCode() {
-	case fasthttp.StatusOK:
-		return resp.Body(), nil
-	case fasthttp.StatusUnauthorized:
-		return nil, errors.New("OpenFaaS Credentials are invalid")
-	case fasthttp.StatusNotFound:
-		return nil, errors.New(fmt.Sprintf("Function %s is not deployed", name))
+	result.StatusCode = resp.StatusCode()
+	result.Body = append([]byte{}, resp.Body()...)
+	result.Header = make(http.Header)
+	resp.Header.VisitAll(func(key, value []byte) {
+		result.Header.Add(string(key), string(value))
+	})
+
+	// Functions may answer with any successful status, e.g. 202 or 204 if they have nothing to return
+	switch status := resp.StatusCode(); {
+	case status >= fasthttp.StatusOK && status < fasthttp.StatusMultipleChoices:
+		return result, nil
+	case status == fasthttp.StatusUnauthorized:
+		return result, internal.NewInvocationError(name, result.StatusCode, internal.ErrUnauthorized)
+	case status == fasthttp.StatusNotFound:
+		return result, internal.NewInvocationError(name, result.StatusCode, errors.New(fmt.Sprintf("Function %s is not deployed", name)))
 	default:
-		return nil, errors.New(fmt.Sprintf("Received unexpected Status Code %d", resp.StatusCode()))
+		return result, internal.NewInvocationError(name, result.StatusCode, errors.New(fmt.Sprintf("Received unexpected Status Code %d", resp.StatusCode())))
 	}
 }
 
@@ -123,12 +256,8 @@ func (c *Client) InvokeAsync(ctx context.Context, name string, invocation *inter
 	req.Header.Set("Content-Type", invocation.ContentType)
 	req.Header.Set("Content-Encoding", invocation.ContentEncoding)
 	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
-	if c.credentials != nil {
-		credentials := c.credentials.User + ":" + c.credentials.Password
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
-	}
 
-	err := c.client.Do(req, resp)
+	err := c.do(ctx, req, resp)
 	if err != nil {
 		return false, errors.Wrapf(err, "unable to invoke function %s", name)
 	}
@@ -137,7 +266,7 @@ func (c *Client) InvokeAsync(ctx context.Context, name string, invocation *inter
 	case fasthttp.StatusAccepted:
 		return true, nil
 	case fasthttp.StatusUnauthorized:
-		return false, errors.New("OpenFaaS Credentials are invalid")
+		return false, internal.ErrUnauthorized
 	case fasthttp.StatusNotFound:
 		return false, errors.New(fmt.Sprintf("Function %s is not deployed", name))
 	default:
@@ -158,12 +287,8 @@ func (c *Client) HasNamespaceSupport(ctx context.Context) (bool, error) {
 
 	req.Header.SetMethod(fasthttp.MethodGet)
 	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
-	if c.credentials != nil {
-		credentials := c.credentials.User + ":" + c.credentials.Password
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
-	}
 
-	err := c.client.Do(req, resp)
+	err := c.do(ctx, req, resp)
 	if err != nil {
 		return false, errors.Wrapf(err, "unable to determine namespace support")
 	}
@@ -175,7 +300,7 @@ func (c *Client) HasNamespaceSupport(ctx context.Context) (bool, error) {
 		// Swarm edition of OF does not support namespaces and is simply returning empty array
 		return len(namespaces) > 0, nil
 	case fasthttp.StatusUnauthorized:
-		return false, errors.New("OpenFaaS Credentials are invalid")
+		return false, internal.ErrUnauthorized
 	default:
 		log.Printf("Received unexpected Status Code %d while fetching namespaces\n", resp.StatusCode())
 		return false, nil
@@ -195,12 +320,8 @@ func (c *Client) GetNamespaces(ctx context.Context) ([]string, error) {
 
 	req.Header.SetMethod(fasthttp.MethodGet)
 	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
-	if c.credentials != nil {
-		credentials := c.credentials.User + ":" + c.credentials.Password
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
-	}
 
-	err := c.client.Do(req, resp)
+	err := c.do(ctx, req, resp)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to fetch namespaces")
 	}
@@ -212,7 +333,7 @@ func (c *Client) GetNamespaces(ctx context.Context) ([]string, error) {
 		// Swarm edition of OF does not support namespaces and is simply returning empty array
 		return namespaces, nil
 	case fasthttp.StatusUnauthorized:
-		return nil, errors.New("OpenFaaS Credentials are invalid")
+		return nil, internal.ErrUnauthorized
 	default:
 		log.Printf("Received unexpected Status Code %d while fetching namespaces\n", resp.StatusCode())
 		return nil, nil
@@ -232,16 +353,12 @@ func (c *Client) GetFunctions(ctx context.Context, namespace string) ([]types.Fu
 
 	req.Header.SetMethod(fasthttp.MethodGet)
 	req.Header.SetUserAgent("OpenFaaS - Rabbit MQ Connector")
-	if c.credentials != nil {
-		credentials := c.credentials.User + ":" + c.credentials.Password
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
-	}
 
 	if len(namespace) > 0 {
 		req.URI().QueryArgs().Add("namespace", namespace)
 	}
 
-	err := c.client.Do(req, resp)
+	err := c.do(ctx, req, resp)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to obtain functions")
 	}
@@ -253,7 +370,7 @@ func (c *Client) GetFunctions(ctx context.Context, namespace string) ([]types.Fu
 		// Swarm edition of OF does not support namespaces and is simply returning empty array
 		return functions, nil
 	case fasthttp.StatusUnauthorized:
-		return nil, errors.New("OpenFaaS Credentials are invalid")
+		return nil, internal.ErrUnauthorized
 	default:
 		return nil, errors.New(fmt.Sprintf("Received unexpected Status Code %d", resp.StatusCode()))
 	}
@@ -7,14 +7,17 @@ package openfaas
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	types2 "github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/spf13/afero"
 	"github.com/valyala/fasthttp"
 
 	"github.com/openfaas/faas-provider/auth"
@@ -23,7 +26,7 @@ import (
 )
 
 func CreateClient(server *httptest.Server) *fasthttp.Client {
-	client := types2.MakeHTTPClient(true, 256, 30*time.Second)
+	client := types2.MakeHTTPClient(&tls.Config{InsecureSkipVerify: true}, 256, 30*time.Second)
 	// TODO: For the future configure client with cert pool from the server
 	return client
 }
@@ -53,6 +56,12 @@ func TestClient_InvokeSync(t *testing.T) {
 		case "/function/exists":
 			w.WriteHeader(200)
 			fmt.Fprint(w, expectedResponse)
+		case "/function/accepting":
+			w.WriteHeader(202)
+		case "/function/silent":
+			w.WriteHeader(204)
+		case "/function/redirecting":
+			w.WriteHeader(304)
 		case "/function/nonexisting":
 			w.WriteHeader(404)
 			fmt.Fprint(w, "Not Found")
@@ -65,10 +74,10 @@ func TestClient_InvokeSync(t *testing.T) {
 
 	openfaasClient := NewClient(CreateClient(server), nil, server.URL)
 
-	authenticatedOpenFaaSClient := NewClient(CreateClient(server), &auth.BasicAuthCredentials{
+	authenticatedOpenFaaSClient := NewClient(CreateClient(server), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Invalid",
-	}, server.URL)
+	}), server.URL)
 
 	message := []byte("Test")
 	payload := types2.OpenFaaSInvocation{
@@ -90,32 +99,59 @@ func TestClient_InvokeSync(t *testing.T) {
 		resp, err := openfaasClient.InvokeSync(context.Background(), "exists", &payload)
 
 		assert.Nil(t, err, "Should not fail")
-		assert.Equal(t, string(resp), expectedResponse, "Did not receive expected response")
+		assert.Equal(t, string(resp.Body), expectedResponse, "Did not receive expected response")
+		assert.Equal(t, "exists", resp.Function)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Greater(t, resp.Duration, time.Duration(0))
 	})
 
 	t.Run("Should except nil as body", func(t *testing.T) {
 		resp, err := openfaasClient.InvokeSync(context.Background(), "exists", &nilPayload)
 
 		assert.Nil(t, err, "Should not fail")
-		assert.Equal(t, string(resp), expectedResponse, "Did not receive expected response")
+		assert.Equal(t, string(resp.Body), expectedResponse, "Did not receive expected response")
+	})
+
+	t.Run("Should accept every successful status code", func(t *testing.T) {
+		for function, status := range map[string]int{"accepting": 202, "silent": 204} {
+			resp, err := openfaasClient.InvokeSync(context.Background(), function, &payload)
+
+			assert.NoError(t, err, "Should not fail for %d", status)
+			assert.Equal(t, status, resp.StatusCode)
+			assert.Equal(t, types2.ClassNone, types2.Classify(resp.StatusCode, err))
+		}
+	})
+
+	t.Run("Should throw error on other status codes than successful ones", func(t *testing.T) {
+		resp, err := openfaasClient.InvokeSync(context.Background(), "redirecting", &payload)
+
+		assert.Error(t, err, "Received unexpected Status Code 304", "Did receive unexpected error")
+		assert.Equal(t, 304, resp.StatusCode)
 	})
 
 	t.Run("Should throw error if function does not exist", func(t *testing.T) {
-		_, err := openfaasClient.InvokeSync(context.Background(), "nonexisting", &payload)
+		resp, err := openfaasClient.InvokeSync(context.Background(), "nonexisting", &payload)
 
 		assert.Error(t, err, "Function nonexisting is not deployed", "Did receive unexpected error")
+		assert.ErrorIs(t, err, types2.ErrNotFound)
+		assert.Equal(t, 404, resp.StatusCode)
 	})
 
 	t.Run("Should throw error if unauthorized", func(t *testing.T) {
 		_, err := authenticatedOpenFaaSClient.InvokeSync(context.Background(), "exists", &nilPayload)
 
 		assert.Error(t, err, "OpenFaaS Credentials are invalid", "Did receive unexpected error")
+		assert.ErrorIs(t, err, types2.ErrUnauthorized)
 	})
 
 	t.Run("Should throw error on unexpected status code", func(t *testing.T) {
-		_, err := openfaasClient.InvokeSync(context.Background(), "internal", &payload)
+		resp, err := openfaasClient.InvokeSync(context.Background(), "internal", &payload)
 
 		assert.Error(t, err, "Received unexpected Status Code 500", "Did receive unexpected error")
+		assert.ErrorIs(t, err, types2.ErrServerError)
+		assert.Equal(t, 500, resp.StatusCode)
+		assert.Equal(t, "Internal Server Error", string(resp.Body))
 	})
 }
 
@@ -155,10 +191,10 @@ func TestClient_InvokeAsync(t *testing.T) {
 
 	openfaasClient := NewClient(CreateClient(server), nil, server.URL)
 
-	authenticatedOpenFaaSClient := NewClient(CreateClient(server), &auth.BasicAuthCredentials{
+	authenticatedOpenFaaSClient := NewClient(CreateClient(server), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Invalid",
-	}, server.URL)
+	}), server.URL)
 
 	message := []byte("Test")
 	payload := types2.OpenFaaSInvocation{
@@ -249,15 +285,15 @@ func TestClient_HasNamespaceSupport(t *testing.T) {
 
 	ofSwarmClient := NewClient(CreateClient(swarmOF), nil, swarmOF.URL)
 
-	authenticatedOpenFaaSClient := NewClient(CreateClient(k8sOF), &auth.BasicAuthCredentials{
+	authenticatedOpenFaaSClient := NewClient(CreateClient(k8sOF), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Invalid",
-	}, k8sOF.URL)
+	}), k8sOF.URL)
 
-	failingOpenFaaSClient := NewClient(CreateClient(k8sOF), &auth.BasicAuthCredentials{
+	failingOpenFaaSClient := NewClient(CreateClient(k8sOF), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Pass",
-	}, k8sOF.URL)
+	}), k8sOF.URL)
 
 	t.Parallel()
 
@@ -370,10 +406,10 @@ func TestClient_GetFunctions(t *testing.T) {
 
 	openfaasClient := NewClient(CreateClient(server), nil, server.URL)
 
-	authenticatedOpenFaaSClient := NewClient(CreateClient(server), &auth.BasicAuthCredentials{
+	authenticatedOpenFaaSClient := NewClient(CreateClient(server), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Invalid",
-	}, server.URL)
+	}), server.URL)
 
 	t.Parallel()
 
@@ -448,15 +484,15 @@ func TestClient_GetNamespaces(t *testing.T) {
 
 	openfaasClient := NewClient(CreateClient(server), nil, server.URL)
 
-	authenticatedOpenFaaSClient := NewClient(CreateClient(server), &auth.BasicAuthCredentials{
+	authenticatedOpenFaaSClient := NewClient(CreateClient(server), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Invalid",
-	}, server.URL)
+	}), server.URL)
 
-	failingOpenFaaSClient := NewClient(CreateClient(server), &auth.BasicAuthCredentials{
+	failingOpenFaaSClient := NewClient(CreateClient(server), NewBasicAuth(&auth.BasicAuthCredentials{
 		User:     "User",
 		Password: "Pass",
-	}, server.URL)
+	}), server.URL)
 
 	t.Parallel()
 
@@ -510,3 +546,127 @@ func TestClient_Edge(t *testing.T) {
 		assert.Error(t, err, "unsupported protocol ftp. http and https are supported", "Did receive unexpected error")
 	})
 }
+
+func TestClient_Authorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "[]")
+	}))
+	defer server.Close()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "token", []byte("abc"), 0600)
+
+	t.Run("Should send header of the auth provider", func(t *testing.T) {
+		client := NewClient(CreateClient(server), NewBearerTokenFile(fs, "token"), server.URL)
+
+		functions, err := client.GetFunctions(context.Background(), "")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Empty(t, functions)
+	})
+
+	t.Run("Should return error if auth provider fails", func(t *testing.T) {
+		client := NewClient(CreateClient(server), NewBearerTokenFile(fs, "missing"), server.URL)
+
+		_, err := client.InvokeSync(context.Background(), "figlet", &types2.OpenFaaSInvocation{})
+
+		assert.Error(t, err, "should throw")
+		assert.Contains(t, err.Error(), "unable to authenticate towards OpenFaaS")
+	})
+
+	t.Run("Should retry with rotated credentials if they got refused", func(t *testing.T) {
+		rotating := afero.NewMemMapFs()
+		_ = afero.WriteFile(rotating, "token", []byte("outdated"), 0600)
+		provider := NewBearerTokenFile(rotating, "token")
+		client := NewClient(CreateClient(server), provider, server.URL)
+
+		_, err := client.GetFunctions(context.Background(), "")
+		assert.ErrorIs(t, err, types2.ErrUnauthorized, "should refuse outdated token")
+
+		_ = afero.WriteFile(rotating, "token", []byte("abc"), 0600)
+		functions, err := client.GetFunctions(context.Background(), "")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Empty(t, functions)
+	})
+}
+
+func TestClient_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/function/slow" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		fmt.Fprint(w, r.Header.Get(TimeoutHeader))
+	}))
+	defer server.Close()
+
+	client := NewClient(types2.MakeHTTPClient(nil, 256, 0), nil, server.URL)
+	message := []byte("Test")
+
+	t.Run("Should pass remaining budget to the function", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resp, err := client.InvokeSync(ctx, "echo", &types2.OpenFaaSInvocation{Message: &message})
+
+		assert.NoError(t, err, "Should not fail")
+		budget, _ := strconv.Atoi(string(resp.Body))
+		assert.InDelta(t, 10000, budget, 1000, "Should pass remaining milliseconds")
+	})
+
+	t.Run("Should not pass budget without deadline", func(t *testing.T) {
+		resp, err := client.InvokeSync(context.Background(), "echo", &types2.OpenFaaSInvocation{Message: &message})
+
+		assert.NoError(t, err, "Should not fail")
+		assert.Empty(t, resp.Body)
+	})
+
+	t.Run("Should abort invocation once the deadline passed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.InvokeSync(ctx, "slow", &types2.OpenFaaSInvocation{Message: &message})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, types2.ErrTimeout)
+		assert.Less(t, time.Since(start), 400*time.Millisecond, "Should not wait for the function")
+	})
+
+	t.Run("Should fall back to timeout of the client", func(t *testing.T) {
+		limited := NewClient(types2.MakeHTTPClient(nil, 256, 0), nil, server.URL).WithTimeout(50 * time.Millisecond)
+
+		_, err := limited.GetFunctions(context.Background(), "slow")
+		assert.NoError(t, err, "Should not fail for fast requests")
+
+		_, err = limited.InvokeSync(context.Background(), "slow", &types2.OpenFaaSInvocation{Message: &message})
+		assert.ErrorIs(t, err, fasthttp.ErrTimeout)
+		assert.ErrorIs(t, err, types2.ErrTimeout)
+	})
+
+	t.Run("Should not invoke with cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := client.InvokeSync(ctx, "echo", &types2.OpenFaaSInvocation{Message: &message})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Should abort outstanding invocation once the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		resp, err := client.InvokeSync(ctx, "slow", &types2.OpenFaaSInvocation{Message: &message})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, resp.StatusCode, "Should not have received a response")
+		assert.Less(t, time.Since(start), 400*time.Millisecond, "Should not wait for the function")
+	})
+}
@@ -6,8 +6,9 @@
 package rabbitmq
 
 import (
-	"crypto/tls"
+	"os"
 
+	"github.com/Templum/rabbitmq-connector/pkg/version"
 	"github.com/streadway/amqp"
 )
 
@@ -20,14 +21,55 @@ func NewBroker() RBDialer {
 // unit testing. By abstracting away the RabbitMQ raw types, which are struct based.
 type Broker struct{}
 
-// Dial tries to connect to the providing url, returning either a RBConnection or
-// the received connection error.
-func (b *Broker) Dial(url string) (RBConnection, error) {
-	return amqp.Dial(url)
+// DialConfig tries to connect to the providing url using the provided config, which includes the TLS config
+// as well as the tuning of the connection. Returning either a RBConnection or the received connection error.
+func (b *Broker) DialConfig(url string, conf amqp.Config) (RBConnection, error) {
+	return amqp.DialConfig(url, conf)
 }
 
-// DialTLS tries to connect to the providing url using TLS, returning either a RBConnection or
-// the received connection error.
-func (b *Broker) DialTLS(url string, conf *tls.Config) (RBConnection, error) {
-	return amqp.DialTLS(url, conf)
+// ClientProperties returns the properties advertised to the server, which identify the connector instance in the management UI
+func ClientProperties(connectionName string, instanceID string) amqp.Table {
+	commit, release := version.GetReleaseInfo()
+	hostname, _ := os.Hostname()
+
+	return amqp.Table{
+		"product":         "rabbitmq-connector",
+		"version":         release,
+		"commit":          commit,
+		"platform":        "golang",
+		"information":     "https://github.com/Templum/rabbitmq-connector",
+		"connection_name": connectionName,
+		"hostname":        hostname,
+		"instance_id":     instanceID,
+	}
+}
+
+// Supported SASL mechanisms
+const (
+	AuthPlain    = "PLAIN"
+	AuthExternal = "EXTERNAL"
+)
+
+// ExternalAuth implements the SASL EXTERNAL mechanism, where RabbitMQ derives the user from the client certificate
+// presented during the TLS handshake. It requires the rabbitmq_auth_mechanism_ssl plugin.
+type ExternalAuth struct{}
+
+// Mechanism returns the name of the SASL mechanism
+func (a *ExternalAuth) Mechanism() string {
+	return AuthExternal
+}
+
+// Response is empty, as the identity is established by the TLS handshake
+func (a *ExternalAuth) Response() string {
+	return ""
+}
+
+// Authentication returns the SASL mechanisms for the provided mechanism name, PLAIN uses the credentials of the url
+// and therefore does not need to be configured.
+func Authentication(mechanism string) []amqp.Authentication {
+	if mechanism == AuthExternal {
+		return []amqp.Authentication{&ExternalAuth{}}
+	}
+
+	return nil
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -61,7 +62,7 @@ func TestBroker_Dial(t *testing.T) {
 	broker := NewBroker()
 	url := fmt.Sprintf("amqp://user:pass@%s:%s/", ip, port.Port())
 
-	con, err := broker.Dial(url)
+	con, err := broker.DialConfig(url, amqp.Config{})
 
 	assert.NotNil(t, con, "should return connection")
 	assert.NoError(t, err, "should not throw")
@@ -101,7 +102,7 @@ func TestBroker_DialTLS(t *testing.T) {
 	broker := NewBroker()
 	url := fmt.Sprintf("amqps://%s:%s/", ip, port.Port())
 
-	con, err := broker.DialTLS(url, &tls.Config{})
+	con, err := broker.DialConfig(url, amqp.Config{TLSClientConfig: &tls.Config{}})
 
 	assert.Nil(t, con, "should not support TLS")
 	assert.Error(t, err, "should raise an error")
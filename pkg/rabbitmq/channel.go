@@ -6,8 +6,6 @@
 package rabbitmq
 
 import (
-	"crypto/tls"
-
 	"github.com/streadway/amqp"
 )
 
@@ -27,19 +25,22 @@ type ChannelConsumer interface {
 // on the RabbitMQ cluster
 type ExchangeHandler interface {
 	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	ExchangeDelete(name string, ifUnused, noWait bool) error
 }
 
 // QueueHandler offers a interface for the decleration & binding of an queues. Further it allows the validation against existing queues
 // on the RabbitMQ cluster
 type QueueHandler interface {
 	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
 	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error)
 }
 
 // RBDialer is a abstraction of the RabbitMQ Dial methods
 type RBDialer interface {
-	Dial(url string) (RBConnection, error)
-	DialTLS(url string, conf *tls.Config) (RBConnection, error)
+	DialConfig(url string, conf amqp.Config) (RBConnection, error)
 }
 
 // RabbitChannel is a abstraction of a RabbitMQ Channel
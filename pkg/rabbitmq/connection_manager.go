@@ -11,13 +11,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Templum/rabbitmq-connector/pkg/types"
 	"github.com/pkg/errors"
 	"github.com/streadway/amqp"
 )
 
+// ErrConnectionFailed is returned once the reconnect policy is exhausted without establishing a connection
+var ErrConnectionFailed = errors.New("could not establish connection to Rabbit MQ Cluster")
+
+// ErrDisconnected is returned if the manager got disconnected while still trying to connect
+var ErrDisconnected = errors.New("connection manager was disconnected")
+
 // Connector is a high level interface for connection related methods
 type Connector interface {
-	Connect(connectionURL string) (<-chan *amqp.Error, error)
+	Connect(connectionURLs []string) (<-chan *amqp.Error, error)
 	Disconnect()
 }
 
@@ -26,54 +33,154 @@ type Connector interface {
 type Manager interface {
 	Connector
 	ChannelCreator
+	Replace(connectionURLs []string) (<-chan *amqp.Error, func(), error)
+	UpdateTLSConfig(conf *tls.Config)
+}
+
+// ConnectionOptions configures how the ConnectionManager connects to the nodes of the cluster
+type ConnectionOptions struct {
+	TLSConfig *tls.Config
+	Policy    types.ReconnectPolicy
+	Strategy  NodeStrategy
+	// Locator is optional, if present the node it reports is tried first
+	Locator LeaderLocator
+
+	// Heartbeat below 1s uses the interval of the server, defaults to 10s
+	Heartbeat time.Duration
+	// ChannelMax of 0 allows 2^16 - 1 channels
+	ChannelMax int
+	// FrameSize of 0 does not limit the frame size
+	FrameSize int
+	// DialTimeout limits establishing the connection including the handshakes, defaults to 30s
+	DialTimeout time.Duration
+	// Locale defaults to en_US
+	Locale string
+	// Properties are advertised to the server, see ClientProperties
+	Properties amqp.Table
+	// SASL replaces authenticating with the credentials of the url, see Authentication
+	SASL []amqp.Authentication
 }
 
 // ConnectionManager is tasked with managing the connection Rabbit MQ
 type ConnectionManager struct {
-	con     RBConnection
-	lock    sync.RWMutex
-	dialer  RBDialer
-	tlsConf *tls.Config
+	con    RBConnection
+	lock   sync.RWMutex
+	dialer RBDialer
+	opts   ConnectionOptions
+	next   int
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewConnectionManager creates a new instance using the provided dialer
-func NewConnectionManager(dialer RBDialer, conf *tls.Config) Manager {
+// NewConnectionManager creates a new instance using the provided dialer, which retries connecting according to the options
+func NewConnectionManager(dialer RBDialer, opts ConnectionOptions) Manager {
 	return &ConnectionManager{
-		lock:    sync.RWMutex{},
-		con:     nil,
-		dialer:  dialer,
-		tlsConf: conf,
+		lock:   sync.RWMutex{},
+		con:    nil,
+		dialer: dialer,
+		opts:   opts,
+
+		done: make(chan struct{}),
 	}
 }
 
-// Connect uses the provided connection urls and tries up to 3 times to establish a connection.
-// The retries are performed exponentially starting with 2s. It also creates a listener for close notifications.
-func (m *ConnectionManager) Connect(connectionURL string) (<-chan *amqp.Error, error) {
-	for attempt := 0; attempt < 3; attempt++ {
+// Connect establishes a connection to one of the nodes described by the connection urls. Within an attempt every node
+// is tried once in the order of the node strategy, attempts are retried with the delays of the reconnect policy until
+// it is exhausted. Fatal errors like refused credentials are returned right away, as retrying does not resolve them.
+// An existing connection is replaced. It also creates a listener for close notifications.
+func (m *ConnectionManager) Connect(connectionURLs []string) (<-chan *amqp.Error, error) {
+	closeChannel, previous, err := m.connect(connectionURLs)
+	if previous != nil {
+		_ = previous.Close()
+	}
+
+	return closeChannel, err
+}
 
-		con, err := m.dial(connectionURL)
+// Replace establishes a new connection like Connect, e.g. to pick up rotated credentials. In contrast to Connect the
+// previous connection is kept open until the returned release function is called, so deliveries in flight on it can
+// still be acknowledged. Channels created afterwards use the new connection.
+func (m *ConnectionManager) Replace(connectionURLs []string) (<-chan *amqp.Error, func(), error) {
+	closeChannel, previous, err := m.connect(connectionURLs)
 
-		if err == nil {
-			log.Println("Successfully established connection to Rabbit MQ Cluster")
-			m.lock.Lock()
-			m.con = con
-			m.lock.Unlock()
+	release := func() {
+		if previous == nil {
+			return
+		}
 
-			closeChannel := make(chan *amqp.Error)
-			con.NotifyClose(closeChannel)
-			return closeChannel, nil
+		if closeErr := previous.Close(); closeErr != nil {
+			log.Printf("Received %s during closing replaced connection", closeErr)
 		}
+	}
 
-		log.Printf("Failed to establish connection due to %s. Attempt: %d/3", err, attempt)
-		time.Sleep(time.Duration(2*attempt+1) * time.Second)
+	return closeChannel, release, err
+}
+
+func (m *ConnectionManager) connect(connectionURLs []string) (<-chan *amqp.Error, RBConnection, error) {
+	if len(connectionURLs) == 0 {
+		return nil, nil, errors.New("no Rabbit MQ node to connect to")
 	}
 
-	return nil, errors.New("could not establish connection to Rabbit MQ Cluster")
+	for attempt := 1; ; attempt++ {
+		var err error
+
+		for _, connectionURL := range m.order(connectionURLs) {
+			var con RBConnection
+			con, err = m.dial(connectionURL)
+
+			if err == nil {
+				log.Printf("Successfully established connection to Rabbit MQ Cluster via %s", SanitizeURL(connectionURL))
+				m.lock.Lock()
+				previous := m.con
+				m.con = con
+				m.next = (indexOf(connectionURLs, connectionURL) + 1) % len(connectionURLs)
+				m.lock.Unlock()
+
+				closeChannel := make(chan *amqp.Error)
+				con.NotifyClose(closeChannel)
+				return closeChannel, previous, nil
+			}
+
+			if IsFatal(err) {
+				return nil, nil, errors.Wrap(err, "could not establish connection to Rabbit MQ Cluster")
+			}
+
+			if len(connectionURLs) > 1 {
+				log.Printf("Failed to connect to %s due to %s", SanitizeURL(connectionURL), err)
+			}
+		}
+
+		if m.opts.Policy.Exhausted(attempt) {
+			log.Printf("Failed to establish connection due to %s. Attempt: %d/%d", err, attempt, m.opts.Policy.MaxAttempts)
+			return nil, nil, ErrConnectionFailed
+		}
+
+		delay := m.opts.Policy.Delay(attempt)
+		log.Printf("Failed to establish connection due to %s. Attempt: %d, will retry in %s", err, attempt, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-m.done:
+			return nil, nil, ErrDisconnected
+		}
+	}
 }
 
-// Disconnect closes the connection and frees up the reference
+// Disconnect closes the connection and frees up the reference, further it stops pending connection attempts
 func (m *ConnectionManager) Disconnect() {
+	m.closeOnce.Do(func() {
+		if m.done != nil {
+			close(m.done)
+		}
+	})
+
 	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.con == nil {
+		return
+	}
 
 	err := m.con.Close()
 	if err != nil {
@@ -81,7 +188,6 @@ func (m *ConnectionManager) Disconnect() {
 	}
 
 	m.con = nil
-	m.lock.Unlock()
 }
 
 // Channel creates a new Rabbit MQ channel on the existing connection
@@ -91,10 +197,92 @@ func (m *ConnectionManager) Channel() (RabbitChannel, error) {
 	return m.con.Channel()
 }
 
+// UpdateTLSConfig replaces the TLS config, e.g. after certificates got rotated. Existing connections are not affected,
+// the config is used for all connections established afterwards.
+func (m *ConnectionManager) UpdateTLSConfig(conf *tls.Config) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.opts.TLSConfig = conf
+}
+
+// order returns the urls in the order they should be tried, preferring the node reported by the locator
+func (m *ConnectionManager) order(connectionURLs []string) []string {
+	preferred := ""
+	if m.opts.Locator != nil {
+		host, err := m.opts.Locator.LeaderHost()
+		if err != nil {
+			log.Printf("Received %s while locating queue leaders, will not prefer any node", err)
+		}
+		preferred = host
+	}
+
+	m.lock.RLock()
+	next := m.next
+	m.lock.RUnlock()
+
+	return orderNodes(connectionURLs, m.opts.Strategy, next, preferred)
+}
+
+func indexOf(values []string, target string) int {
+	for i, value := range values {
+		if value == target {
+			return i
+		}
+	}
+
+	return -1
+}
+
 func (m *ConnectionManager) dial(connectionURL string) (RBConnection, error) {
-	if m.tlsConf == nil {
-		return m.dialer.Dial(connectionURL)
+	return m.dialer.DialConfig(connectionURL, m.config())
+}
+
+// config translates the options into the config of the client library, applying the same defaults as amqp.Dial.
+// The TLS config is cloned, as the client library sets the server name to the host of the node if it is not specified.
+func (m *ConnectionManager) config() amqp.Config {
+	m.lock.RLock()
+	tlsConf := m.opts.TLSConfig
+	m.lock.RUnlock()
+
+	conf := amqp.Config{
+		Heartbeat:       m.opts.Heartbeat,
+		ChannelMax:      m.opts.ChannelMax,
+		FrameSize:       m.opts.FrameSize,
+		TLSClientConfig: tlsConf.Clone(),
+		Locale:          m.opts.Locale,
+		SASL:            m.opts.SASL,
+	}
+
+	if conf.Heartbeat == 0 {
+		conf.Heartbeat = 10 * time.Second
+	}
+
+	if len(conf.Locale) == 0 {
+		conf.Locale = "en_US"
+	}
+
+	if m.opts.DialTimeout > 0 {
+		conf.Dial = amqp.DefaultDial(m.opts.DialTimeout)
+	}
+
+	if len(m.opts.Properties) > 0 {
+		// The client library adds its capabilities to the properties, so every connection gets its own copy
+		conf.Properties = amqp.Table{}
+		for key, value := range m.opts.Properties {
+			conf.Properties[key] = value
+		}
+	}
+
+	return conf
+}
+
+// IsFatal reports whether the error is caused by refused credentials or permissions, which retrying does not resolve
+func IsFatal(err error) bool {
+	if errors.Is(err, amqp.ErrCredentials) || errors.Is(err, amqp.ErrSASL) || errors.Is(err, amqp.ErrVhost) {
+		return true
 	}
 
-	return m.dialer.DialTLS(connectionURL, m.tlsConf)
+	var amqpErr *amqp.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == amqp.AccessRefused
 }
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+)
+
+// EffectiveTopology returns a copy of the topology as the connector applies it, with every exchange type coerced
+// to one of the supported types.
+func EffectiveTopology(t types.Topology) types.Topology {
+	out := make(types.Topology, 0, len(t))
+
+	for _, definition := range t {
+		ex := definition
+		ex.Topics = append([]string{}, definition.Topics...)
+		ex.EnsureCorrectType()
+		out = append(out, ex)
+	}
+
+	return out
+}
+
+// ExportDefinitions renders the effective topology as RabbitMQ definitions. They contain the exchanges, queues
+// and bindings the connector declares, so exchanges that are not declared by the connector are omitted.
+func ExportDefinitions(t types.Topology, vhost string) types.Definitions {
+	defs := types.Definitions{
+		Exchanges: []types.DefinitionExchange{},
+		Queues:    []types.DefinitionQueue{},
+		Bindings:  []types.DefinitionBinding{},
+	}
+
+	for _, ex := range EffectiveTopology(t) {
+		if ex.Declare {
+			defs.Exchanges = append(defs.Exchanges, types.DefinitionExchange{
+				Name:       ex.Name,
+				VHost:      vhost,
+				Type:       ex.Type,
+				Durable:    ex.Durable,
+				AutoDelete: ex.AutoDeleted,
+				Internal:   false,
+				Arguments:  map[string]interface{}{},
+			})
+		}
+
+		for _, topic := range ex.Topics {
+			name := GenerateQueueName(ex.Name, topic)
+
+			defs.Queues = append(defs.Queues, types.DefinitionQueue{
+				Name:       name,
+				VHost:      vhost,
+				Durable:    ex.Durable,
+				AutoDelete: ex.AutoDeleted,
+				Arguments:  map[string]interface{}{},
+			})
+
+			defs.Bindings = append(defs.Bindings, types.DefinitionBinding{
+				Source:          ex.Name,
+				VHost:           vhost,
+				Destination:     name,
+				DestinationType: "queue",
+				RoutingKey:      topic,
+				Arguments:       map[string]interface{}{},
+			})
+		}
+	}
+
+	return defs
+}
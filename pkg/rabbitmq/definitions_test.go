@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveTopology(t *testing.T) {
+	t.Run("Should coerce types without modifying the topology", func(t *testing.T) {
+		topology := types.Topology{
+			{Name: "AEx", Topics: []string{"Foo"}, Declare: true, Type: "TOPIC"},
+			{Name: "BEx", Topics: []string{"Bar"}, Declare: true, Type: "fanout"},
+		}
+
+		actual := EffectiveTopology(topology)
+
+		assert.Equal(t, "topic", actual[0].Type)
+		assert.Equal(t, "direct", actual[1].Type)
+		assert.Equal(t, "TOPIC", topology[0].Type, "should not modify original topology")
+	})
+}
+
+func TestExportDefinitions(t *testing.T) {
+	topology := types.Topology{
+		{Name: "AEx", Topics: []string{"Foo", "Bar"}, Declare: true, Durable: true},
+		{Name: "amq.topic", Topics: []string{"Baz"}, Declare: false, Type: "topic"},
+	}
+
+	actual := ExportDefinitions(topology, "/staging")
+
+	t.Run("Should only contain declared exchanges", func(t *testing.T) {
+		assert.Len(t, actual.Exchanges, 1)
+		assert.Equal(t, types.DefinitionExchange{Name: "AEx", VHost: "/staging", Type: "direct", Durable: true, Arguments: map[string]interface{}{}}, actual.Exchanges[0])
+	})
+
+	t.Run("Should contain generated queues", func(t *testing.T) {
+		assert.Len(t, actual.Queues, 3)
+		assert.Equal(t, types.DefinitionQueue{Name: "OpenFaaS_AEx_Foo", VHost: "/staging", Durable: true, Arguments: map[string]interface{}{}}, actual.Queues[0])
+		assert.Equal(t, "OpenFaaS_amq.topic_Baz", actual.Queues[2].Name)
+	})
+
+	t.Run("Should contain bindings for every topic", func(t *testing.T) {
+		assert.Len(t, actual.Bindings, 3)
+		assert.Equal(t, types.DefinitionBinding{Source: "amq.topic", VHost: "/staging", Destination: "OpenFaaS_amq.topic_Baz", DestinationType: "queue", RoutingKey: "Baz", Arguments: map[string]interface{}{}}, actual.Bindings[2])
+	})
+
+	t.Run("Should be importable as topology again", func(t *testing.T) {
+		imported := types.TopologyFromDefinitions(actual, "/staging", "")
+
+		assert.Equal(t, types.Topology{{Name: "AEx", Topics: []string{"Foo", "Bar"}, Declare: true, Type: "direct", Durable: true}}, imported[:1])
+	})
+}
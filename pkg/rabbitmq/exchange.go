@@ -6,6 +6,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"log"
 	"sync"
 	"time"
@@ -24,10 +25,16 @@ type Stopper interface {
 	Stop()
 }
 
+// Drainer defines something that can be stopped gracefully
+type Drainer interface {
+	Drain()
+}
+
 // ExchangeOrganizer combines the ability to start & stop exchanges
 type ExchangeOrganizer interface {
 	Starter
 	Stopper
+	Drainer
 }
 
 // Exchange contains all of the relevant units to handle communication with an exchange
@@ -37,6 +44,13 @@ type Exchange struct {
 
 	definition *types.Exchange
 	lock       sync.RWMutex
+
+	inflight sync.WaitGroup
+	draining bool
+	drained  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // MaxAttempts of retries that will be performed
@@ -44,12 +58,19 @@ const MaxAttempts = 3
 
 // NewExchange creates a new exchange instance using the provided parameter
 func NewExchange(channel ChannelConsumer, client types.Invoker, definition *types.Exchange) ExchangeOrganizer {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Exchange{
 		channel: channel,
 		client:  client,
 
 		definition: definition,
 		lock:       sync.RWMutex{},
+
+		drained: make(chan struct{}),
+
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -76,18 +97,66 @@ func (e *Exchange) Start() error {
 	return nil
 }
 
-// Stop s consuming messages
+// Stop s consuming messages and cancels the invocations in flight, as their deliveries can no longer be acknowledged
 func (e *Exchange) Stop() {
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
+	e.abort()
 	// We ignore the issue since this method is usually called after connection failure.
 	_ = e.channel.Close()
 }
 
+// lifetime returns the context of the deliveries received by the exchange, which is cancelled once the exchange is stopped
+// or its channel is lost
+func (e *Exchange) lifetime() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+func (e *Exchange) abort() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+// Drain stops invoking functions for further deliveries and waits until the deliveries in flight are acknowledged,
+// before it closes the channel. Deliveries held for a delayed retry are requeued right away. Deliveries received in
+// the meantime are not acknowledged, so RabbitMQ requeues them once the channel is closed.
+func (e *Exchange) Drain() {
+	e.lock.Lock()
+	if !e.draining && e.drained != nil {
+		close(e.drained)
+	}
+	e.draining = true
+	e.lock.Unlock()
+
+	e.inflight.Wait()
+	e.Stop()
+}
+
+// track registers a delivery as in flight, it reports false if the exchange is draining
+func (e *Exchange) track() bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if e.draining {
+		return false
+	}
+
+	e.inflight.Add(1)
+	return true
+}
+
 func (e *Exchange) handleChanFailure(ch <-chan *amqp.Error) {
 	err := <-ch
 	log.Printf("Received following error %s on channel for exchange %s", err, e.definition.Name)
+
+	e.lock.Lock()
+	e.abort()
+	e.lock.Unlock()
 }
 
 // StartConsuming will consume deliveries from the provided channel and if the received delivery
@@ -96,9 +165,16 @@ func (e *Exchange) handleChanFailure(ch <-chan *amqp.Error) {
 func (e *Exchange) StartConsuming(topic string, deliveries <-chan amqp.Delivery) {
 	for delivery := range deliveries {
 		if topic == delivery.RoutingKey {
+			if !e.track() {
+				continue
+			}
+
 			// TODO: Maybe we want to send the deliveries into a general queue
 			// https://medium.com/justforfunc/two-ways-of-merging-n-channels-in-go-43c0b57cd1de
-			go e.handleInvocation(topic, delivery)
+			go func(delivery amqp.Delivery) {
+				defer e.inflight.Done()
+				e.handleInvocation(topic, delivery)
+			}(delivery)
 		} else {
 			log.Printf("Received message for topic %s that did not match subscribed topic %s will reject it", delivery.RoutingKey, topic)
 
@@ -118,32 +194,104 @@ func (e *Exchange) StartConsuming(topic string, deliveries <-chan amqp.Delivery)
 }
 
 func (e *Exchange) handleInvocation(topic string, delivery amqp.Delivery) {
+	invocation := types.NewInvocation(delivery)
+	invocation.Timeout = e.definition.Timeout(topic)
+
+	// Messages past their deadline are not requeued, which would redeliver them over and over again
+	if invocation.Expired() {
+		log.Printf("Delivery %d for topic %s expired before it was invoked, will reject it", delivery.DeliveryTag, topic)
+		e.reject(delivery)
+		return
+	}
+
 	// Call Function via Client
-	err := e.client.Invoke(topic, types.NewInvocation(delivery))
-	if err == nil {
-		for retry := 0; retry < MaxAttempts; retry++ {
-			ackErr := delivery.Ack(false)
-			if ackErr == nil {
-				return
-			}
+	result, err := e.client.Invoke(types.WithDrain(e.lifetime(), e.drained), topic, invocation)
 
-			log.Printf("Failed to acknowledge delivery %d due to %s. Attempt %d/3", delivery.DeliveryTag, ackErr, retry+1)
-			time.Sleep(time.Duration(retry+1*250) * time.Millisecond)
+	action := types.Action{Kind: types.ActionAck}
+	if err != nil {
+		statusCode, class := 0, types.Classify(0, err)
+		if failed := result.Failed(); failed != nil {
+			statusCode, class = failed.StatusCode, failed.Class
 		}
 
-		log.Printf("Failed to acknowledge delivery %d, will abort ack now", delivery.DeliveryTag)
-	} else {
-		for retry := 0; retry < MaxAttempts; retry++ {
-			nackErr := delivery.Nack(false, true)
-			if nackErr == nil {
-				return
-			}
+		action = e.definition.Disposition(topic, statusCode, class)
+		log.Printf("Invocation of delivery %d for topic %s failed with %s, will %s it", delivery.DeliveryTag, topic, class, action)
+	}
+
+	// Functions know best whether a message is bad, so their request overrides the disposition
+	if requested, ok := result.RequestedAction(); ok && requested != action {
+		log.Printf("Function requested to %s delivery %d for topic %s instead of %s", requested, delivery.DeliveryTag, topic, action)
+		action = requested
+	}
+
+	switch action.Kind {
+	case types.ActionAck:
+		e.ack(delivery)
+	case types.ActionReject:
+		e.reject(delivery)
+	case types.ActionRetry:
+		e.retryAfter(delivery, action.Delay)
+	default:
+		e.requeue(delivery)
+	}
+}
+
+func (e *Exchange) ack(delivery amqp.Delivery) {
+	for retry := 0; retry < MaxAttempts; retry++ {
+		ackErr := delivery.Ack(false)
+		if ackErr == nil {
+			return
+		}
+
+		log.Printf("Failed to acknowledge delivery %d due to %s. Attempt %d/3", delivery.DeliveryTag, ackErr, retry+1)
+		time.Sleep(time.Duration(retry+1*250) * time.Millisecond)
+	}
+
+	log.Printf("Failed to acknowledge delivery %d, will abort ack now", delivery.DeliveryTag)
+}
+
+// requeue hands the delivery back to the queue, so RabbitMQ redelivers it
+func (e *Exchange) requeue(delivery amqp.Delivery) {
+	for retry := 0; retry < MaxAttempts; retry++ {
+		nackErr := delivery.Nack(false, true)
+		if nackErr == nil {
+			return
+		}
+
+		log.Printf("Failed to nack delivery %d due to %s. Attempt %d/3", delivery.DeliveryTag, nackErr, retry+1)
+		time.Sleep(time.Duration(retry+1*250) * time.Millisecond)
+	}
+
+	log.Printf("Failed to nack delivery %d, will abort nack now", delivery.DeliveryTag)
+}
+
+// retryAfter holds the delivery for the delay before it requeues it. Once the exchange drains the delivery is requeued
+// right away, once it is stopped the delivery is left to RabbitMQ, which redelivers it as the channel is gone.
+func (e *Exchange) retryAfter(delivery amqp.Delivery, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-e.drained:
+	case <-e.lifetime().Done():
+		return
+	}
+
+	e.requeue(delivery)
+}
 
-			log.Printf("Failed to nack delivery %d due to %s. Attempt %d/3", delivery.DeliveryTag, nackErr, retry+1)
-			time.Sleep(time.Duration(retry+1*250) * time.Millisecond)
+// reject hands the delivery back without requeueing it, so RabbitMQ discards or dead-letters it
+func (e *Exchange) reject(delivery amqp.Delivery) {
+	for retry := 0; retry < MaxAttempts; retry++ {
+		rejectErr := delivery.Reject(false)
+		if rejectErr == nil {
+			return
 		}
 
-		log.Printf("Failed to nack delivery %d, will abort nack now", delivery.DeliveryTag)
+		log.Printf("Failed to reject delivery %d due to %s. Attempt %d/3", delivery.DeliveryTag, rejectErr, retry+1)
+		time.Sleep(time.Duration(retry+1*250) * time.Millisecond)
 	}
 
+	log.Printf("Failed to reject delivery %d, will abort reject now", delivery.DeliveryTag)
 }
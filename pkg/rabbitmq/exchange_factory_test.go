@@ -33,16 +33,36 @@ func (ch *channelMock) QueueDeclare(name string, durable, autoDelete, exclusive,
 	return params.Get(0).(amqp.Queue), params.Error(1)
 }
 
+func (ch *channelMock) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	params := ch.Called(name, durable, autoDelete, exclusive, noWait, args)
+	return params.Get(0).(amqp.Queue), params.Error(1)
+}
+
 func (ch *channelMock) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
 	params := ch.Called(name, key, exchange, noWait, args)
 	return params.Error(0)
 }
 
+func (ch *channelMock) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	params := ch.Called(name, ifUnused, ifEmpty, noWait)
+	return params.Int(0), params.Error(1)
+}
+
 func (ch *channelMock) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
 	params := ch.Called(name, kind, durable, autoDelete, internal, noWait, args)
 	return params.Error(0)
 }
 
+func (ch *channelMock) ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	params := ch.Called(name, kind, durable, autoDelete, internal, noWait, args)
+	return params.Error(0)
+}
+
+func (ch *channelMock) ExchangeDelete(name string, ifUnused, noWait bool) error {
+	params := ch.Called(name, ifUnused, noWait)
+	return params.Error(0)
+}
+
 func (ch *channelMock) Consume(queue string, consumer string, autoAck, exclusive bool, noLocal bool, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
 	params := ch.Called(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
 	return params.Get(0).(<-chan amqp.Delivery), params.Error(1)
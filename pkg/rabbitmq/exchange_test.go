@@ -6,7 +6,9 @@
 package rabbitmq
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"testing"
 	"time"
 
@@ -39,9 +41,9 @@ type invokerMock struct {
 	mock.Mock
 }
 
-func (i *invokerMock) Invoke(topic string, invocation *types.OpenFaaSInvocation) error {
-	args := i.Called(topic, invocation)
-	return args.Error(0)
+func (i *invokerMock) Invoke(ctx context.Context, topic string, invocation *types.OpenFaaSInvocation) (types.InvocationResult, error) {
+	args := i.Called(ctx, topic, invocation)
+	return args.Get(0).(types.InvocationResult), args.Error(1)
 }
 
 func TestExchange_Start(t *testing.T) {
@@ -104,7 +106,7 @@ func TestExchange_StartConsuming(t *testing.T) {
 
 	t.Run("Should invoke function when message is for registered routing key and further ack processing of message if no error occurred", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(nil)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, nil)
 
 		acker := new(acknowledgerMock)
 		acker.On("Ack", mock.Anything, false).Return(nil)
@@ -128,7 +130,7 @@ func TestExchange_StartConsuming(t *testing.T) {
 
 	t.Run("Should attempt to ack successful invocations up to 3 times", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(nil)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, nil)
 
 		acker := new(acknowledgerMock)
 		acker.On("Ack", mock.Anything, false).Return(errors.New("failed"))
@@ -154,7 +156,7 @@ func TestExchange_StartConsuming(t *testing.T) {
 
 	t.Run("Should invoke function when message is for registered routing key and further send back to queue when error occurred", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(errors.New("failed to invoke"))
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, errors.New("failed to invoke"))
 
 		acker := new(acknowledgerMock)
 		acker.On("Nack", mock.Anything, false, true).Return(nil)
@@ -176,9 +178,121 @@ func TestExchange_StartConsuming(t *testing.T) {
 		acker.AssertExpectations(t)
 	})
 
+	t.Run("Should reject delivery without requeueing it if the function refused it", func(t *testing.T) {
+		refused := types.FunctionResult{Function: "biller", StatusCode: 400, Class: types.ClassClientError, Err: errors.New("refused")}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{Topic: "Billing", Functions: []types.FunctionResult{refused}}, refused.Err)
+
+		acker := new(acknowledgerMock)
+		acker.On("Reject", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &definition,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		target.inflight.Wait()
+
+		acker.AssertExpectations(t)
+		acker.AssertNotCalled(t, "Nack", mock.Anything, false, true)
+	})
+
+	t.Run("Should apply the disposition configured for the topic", func(t *testing.T) {
+		withDispositions := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}, Dispositions: map[string]map[string]string{
+			"Billing": {"server-error": "ack"},
+		}}
+		failed := types.FunctionResult{Function: "biller", StatusCode: 500, Class: types.ClassServerError, Err: errors.New("failed")}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{Topic: "Billing", Functions: []types.FunctionResult{failed}}, failed.Err)
+
+		acker := new(acknowledgerMock)
+		acker.On("Ack", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &withDispositions,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		target.inflight.Wait()
+
+		acker.AssertExpectations(t)
+	})
+
+	t.Run("Should hold delivery before requeueing it for a delayed retry", func(t *testing.T) {
+		withDispositions := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}, Dispositions: map[string]map[string]string{
+			"Billing": {"503": "retry-after=100ms"},
+		}}
+		failed := types.FunctionResult{Function: "biller", StatusCode: 503, Class: types.ClassServerError, Err: errors.New("failed")}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{Topic: "Billing", Functions: []types.FunctionResult{failed}}, failed.Err)
+
+		acker := new(acknowledgerMock)
+		acker.On("Nack", mock.Anything, false, true).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &withDispositions,
+		}
+
+		start := time.Now()
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		target.inflight.Wait()
+
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "Should hold the delivery for the delay")
+		acker.AssertExpectations(t)
+	})
+
+	t.Run("Should honour the action requested by the function", func(t *testing.T) {
+		requested := types.FunctionResult{Function: "biller", StatusCode: 200, Header: http.Header{types.ActionHeader: []string{"reject"}}}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{Topic: "Billing", Functions: []types.FunctionResult{requested}}, nil)
+
+		acker := new(acknowledgerMock)
+		acker.On("Reject", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &definition,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		target.inflight.Wait()
+
+		acker.AssertExpectations(t)
+		acker.AssertNotCalled(t, "Ack", mock.Anything, false)
+	})
+
+	t.Run("Should prefer the action requested by the failed function over the disposition", func(t *testing.T) {
+		failed := types.FunctionResult{Function: "biller", StatusCode: 500, Class: types.ClassServerError, Err: errors.New("failed"),
+			Header: http.Header{types.ActionHeader: []string{"ack"}}}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{Topic: "Billing", Functions: []types.FunctionResult{failed}}, failed.Err)
+
+		acker := new(acknowledgerMock)
+		acker.On("Ack", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &definition,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		target.inflight.Wait()
+
+		acker.AssertExpectations(t)
+		acker.AssertNotCalled(t, "Nack", mock.Anything, false, true)
+	})
+
 	t.Run("Should attempt to nack unsuccessful invocations up to 3 times", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(errors.New("failed to invoke"))
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, errors.New("failed to invoke"))
 
 		acker := new(acknowledgerMock)
 		acker.On("Nack", mock.Anything, false, true).Return(errors.New("failed"))
@@ -202,9 +316,58 @@ func TestExchange_StartConsuming(t *testing.T) {
 		acker.AssertNumberOfCalls(t, "Nack", 3)
 	})
 
+	t.Run("Should pass timeout of topic to the invocation", func(t *testing.T) {
+		withTimeout := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}, Timeouts: map[string]string{"Billing": "45s"}}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.MatchedBy(func(invocation *types.OpenFaaSInvocation) bool {
+			return invocation.Timeout == 45*time.Second
+		})).Return(types.InvocationResult{}, nil)
+
+		acker := new(acknowledgerMock)
+		acker.On("Ack", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &withTimeout,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{
+			Acknowledger: acker,
+			RoutingKey:   "Billing",
+			Body:         []byte("Hello World"),
+		}))
+
+		invoker.AssertExpectations(t)
+		acker.AssertExpectations(t)
+	})
+
+	t.Run("Should reject expired messages without invoking functions", func(t *testing.T) {
+		invoker := new(invokerMock)
+
+		acker := new(acknowledgerMock)
+		acker.On("Reject", mock.Anything, false).Return(nil)
+
+		target := Exchange{
+			client:     invoker,
+			definition: &definition,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{
+			Acknowledger: acker,
+			RoutingKey:   "Billing",
+			Expiration:   "1000",
+			Timestamp:    time.Now().Add(-time.Minute),
+			Body:         []byte("Hello World"),
+		}))
+
+		invoker.AssertNotCalled(t, "Invoke", mock.Anything, mock.Anything, mock.Anything)
+		acker.AssertExpectations(t)
+	})
+
 	t.Run("Should not invoke when received message is of no registered topic and further reject message and send it back to queue", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(nil)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, nil)
 
 		acker := new(acknowledgerMock)
 		acker.On("Reject", mock.Anything, true).Return(nil)
@@ -222,13 +385,13 @@ func TestExchange_StartConsuming(t *testing.T) {
 			Body:            []byte("Hello World"),
 		}))
 
-		invoker.AssertNotCalled(t, "Invoke", mock.Anything)
+		invoker.AssertNotCalled(t, "Invoke", mock.Anything, mock.Anything, mock.Anything)
 		acker.AssertExpectations(t)
 	})
 
 	t.Run("Should attempt to reject deliveries for unregistered topics up to 3 times", func(t *testing.T) {
 		invoker := new(invokerMock)
-		invoker.On("Invoke", "Billing", mock.Anything).Return(nil)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, nil)
 
 		acker := new(acknowledgerMock)
 		acker.On("Reject", mock.Anything, true).Return(errors.New("failed"))
@@ -275,4 +438,174 @@ func TestExchange_Stop(t *testing.T) {
 		target.Stop()
 		channel.AssertExpectations(t)
 	})
+
+	t.Run("Should cancel invocations in flight", func(t *testing.T) {
+		definition := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}}
+		invoked := make(chan context.Context, 1)
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			invoked <- ctx
+			<-ctx.Done()
+		}).Return(types.InvocationResult{}, context.Canceled)
+
+		acker := new(acknowledgerMock)
+		acker.On("Nack", mock.Anything, false, true).Return(nil)
+
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+
+		target := NewExchange(channel, invoker, &definition).(*Exchange)
+		go target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+
+		ctx := <-invoked
+		target.Stop()
+
+		assert.ErrorIs(t, ctx.Err(), context.Canceled, "Should cancel the context of the invocation")
+		target.inflight.Wait()
+		acker.AssertExpectations(t)
+	})
+}
+
+func TestExchange_handleChanFailure(t *testing.T) {
+	t.Run("Should cancel invocations once the channel is lost", func(t *testing.T) {
+		definition := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}}
+		target := NewExchange(new(channelMock), new(invokerMock), &definition).(*Exchange)
+
+		closed := make(chan *amqp.Error, 1)
+		closed <- amqp.ErrClosed
+		target.handleChanFailure(closed)
+
+		assert.ErrorIs(t, target.lifetime().Err(), context.Canceled)
+	})
+}
+
+func TestExchange_Drain(t *testing.T) {
+	definition := types.Exchange{
+		Name:   "Nasdaq",
+		Topics: []string{"Billing"},
+	}
+
+	t.Run("Should wait for deliveries in flight before closing the channel", func(t *testing.T) {
+		invoked := make(chan struct{})
+		release := make(chan struct{})
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Run(func(args mock.Arguments) {
+			close(invoked)
+			<-release
+		}).Return(types.InvocationResult{}, nil)
+
+		acker := new(acknowledgerMock)
+		acker.On("Ack", mock.Anything, false).Return(nil)
+
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+
+		target := &Exchange{
+			channel:    channel,
+			client:     invoker,
+			definition: &definition,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+		<-invoked
+
+		drained := make(chan struct{})
+		go func() {
+			target.Drain()
+			close(drained)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		channel.AssertNotCalled(t, "Close", nil)
+
+		close(release)
+		<-drained
+
+		acker.AssertExpectations(t)
+		channel.AssertExpectations(t)
+	})
+
+	t.Run("Should requeue deliveries held for a delayed retry right away", func(t *testing.T) {
+		withDispositions := types.Exchange{Name: "Nasdaq", Topics: []string{"Billing"}, Dispositions: map[string]map[string]string{
+			"Billing": {"default": "retry-after=1m"},
+		}}
+
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Return(types.InvocationResult{}, errors.New("failed to invoke"))
+
+		drained := make(chan struct{})
+		acker := new(acknowledgerMock)
+		acker.On("Nack", mock.Anything, false, true).Return(nil)
+
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+
+		target := NewExchange(channel, invoker, &withDispositions).(*Exchange)
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+
+		go func() {
+			target.Drain()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Should not wait for the delay while draining")
+		}
+
+		acker.AssertExpectations(t)
+		channel.AssertExpectations(t)
+	})
+
+	t.Run("Should signal invocations in flight that the exchange drains", func(t *testing.T) {
+		invoker := new(invokerMock)
+		invoker.On("Invoke", mock.Anything, "Billing", mock.Anything).Run(func(args mock.Arguments) {
+			<-types.Drained(args.Get(0).(context.Context))
+		}).Return(types.InvocationResult{}, types.ErrDraining)
+
+		drained := make(chan struct{})
+		acker := new(acknowledgerMock)
+		acker.On("Nack", mock.Anything, false, true).Return(nil)
+
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+
+		target := NewExchange(channel, invoker, &definition).(*Exchange)
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+
+		go func() {
+			target.Drain()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Should not wait for invocations waiting in between attempts while draining")
+		}
+
+		acker.AssertExpectations(t)
+		channel.AssertExpectations(t)
+	})
+
+	t.Run("Should not invoke functions for deliveries received while draining", func(t *testing.T) {
+		invoker := new(invokerMock)
+		acker := new(acknowledgerMock)
+
+		target := &Exchange{
+			client:     invoker,
+			definition: &definition,
+			draining:   true,
+		}
+
+		target.StartConsuming("Billing", createDeliveries(amqp.Delivery{Acknowledger: acker, RoutingKey: "Billing"}))
+
+		invoker.AssertNotCalled(t, "Invoke", mock.Anything, "Billing", mock.Anything)
+		acker.AssertNotCalled(t, "Ack", mock.Anything, false)
+		acker.AssertNotCalled(t, "Nack", mock.Anything, false, true)
+	})
 }
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeStrategy determines in which order the nodes of a cluster are tried when (re)connecting
+type NodeStrategy string
+
+// Supported strategies for selecting nodes
+const (
+	// RoundRobin tries the nodes in the configured order, starting with the node after the last connected one
+	RoundRobin NodeStrategy = "round-robin"
+	// Shuffle tries the nodes in a random order
+	Shuffle NodeStrategy = "shuffle"
+)
+
+// LeaderLocator reports the host of the node that should be preferred when connecting, e.g. the one hosting the queue leaders
+type LeaderLocator interface {
+	LeaderHost() (string, error)
+}
+
+// orderNodes returns the order in which the urls are tried, where next is the index the round robin strategy starts with
+func orderNodes(urls []string, strategy NodeStrategy, next int, preferredHost string) []string {
+	ordered := make([]string, 0, len(urls))
+
+	if strategy == Shuffle {
+		/* #nosec G404 node order does not need a secure random source */
+		for _, i := range rand.Perm(len(urls)) {
+			ordered = append(ordered, urls[i])
+		}
+	} else {
+		for i := range urls {
+			ordered = append(ordered, urls[(next+i)%len(urls)])
+		}
+	}
+
+	if len(preferredHost) == 0 {
+		return ordered
+	}
+
+	for i, candidate := range ordered {
+		if parsed, err := url.Parse(candidate); err == nil && sameHost(parsed.Hostname(), preferredHost) {
+			return append([]string{candidate}, append(ordered[:i:i], ordered[i+1:]...)...)
+		}
+	}
+
+	return ordered
+}
+
+// sameHost compares hosts, where a short hostname matches the fully qualified one
+func sameHost(a string, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// SanitizeURL removes the credentials from the connection url, so it can be logged
+func SanitizeURL(connectionURL string) string {
+	parsed, err := url.Parse(connectionURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+
+	parsed.User = nil
+	return parsed.String()
+}
+
+// ManagementLocator uses the RabbitMQ management API to find the node hosting the leaders of most of the queues
+type ManagementLocator struct {
+	client *http.Client
+
+	managementURL string
+	vhost         string
+	queues        func() []string
+
+	lock     sync.RWMutex
+	username string
+	password string
+	pinned   bool
+}
+
+// NewManagementLocator creates a locator using the credentials and vhost of the connection url. The TLS config is the
+// one of the management API, which usually differs from the one of the AMQP listener.
+func NewManagementLocator(managementURL string, connectionURL string, conf *tls.Config) (*ManagementLocator, error) {
+	parsed, err := url.Parse(connectionURL)
+	if err != nil {
+		return nil, err
+	}
+
+	password, _ := parsed.User.Password()
+	vhost := strings.TrimPrefix(parsed.Path, "/")
+	if len(vhost) == 0 {
+		vhost = "/"
+	}
+
+	return &ManagementLocator{
+		client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: conf},
+		},
+
+		managementURL: strings.TrimSuffix(managementURL, "/"),
+		username:      parsed.User.Username(),
+		password:      password,
+		vhost:         vhost,
+		queues:        func() []string { return nil },
+	}, nil
+}
+
+// WithCredentials uses credentials of its own for the management API instead of the ones of the connection url, which
+// are no longer updated afterwards
+func (l *ManagementLocator) WithCredentials(username string, password string) *ManagementLocator {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.username = username
+	l.password = password
+	l.pinned = true
+	return l
+}
+
+// WithQueues sets the source of the queues whose leaders are located, it is asked every time a node is located, so
+// queues of reloaded topologies and dynamic bindings are taken into account
+func (l *ManagementLocator) WithQueues(queues func() []string) *ManagementLocator {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.queues = queues
+	return l
+}
+
+// UpdateCredentials replaces the credentials with the ones of the connection url, e.g. after they got rotated. It does
+// nothing if the locator uses credentials of its own.
+func (l *ManagementLocator) UpdateCredentials(connectionURL string) error {
+	parsed, err := url.Parse(connectionURL)
+	if err != nil {
+		return err
+	}
+
+	password, _ := parsed.User.Password()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.pinned {
+		return nil
+	}
+
+	l.username = parsed.User.Username()
+	l.password = password
+	return nil
+}
+
+type queueInfo struct {
+	Node   string `json:"node"`
+	Leader string `json:"leader"`
+}
+
+// LeaderHost returns the host of the node hosting the leaders of most queues. Queues that do not exist yet are ignored,
+// if none of them exists an empty host is returned.
+func (l *ManagementLocator) LeaderHost() (string, error) {
+	count := make(map[string]int)
+	best := ""
+
+	l.lock.RLock()
+	queues := l.queues
+	l.lock.RUnlock()
+
+	for _, queue := range queues() {
+		info, err := l.queue(queue)
+		if err != nil {
+			return "", err
+		}
+		if info == nil {
+			continue
+		}
+
+		node := info.Leader
+		if len(node) == 0 {
+			node = info.Node
+		}
+
+		// Nodes are named like rabbit@hostname
+		host := node[strings.LastIndex(node, "@")+1:]
+		if len(host) == 0 {
+			continue
+		}
+
+		count[host]++
+		if count[host] > count[best] {
+			best = host
+		}
+	}
+
+	return best, nil
+}
+
+func (l *ManagementLocator) queue(name string) (*queueInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/queues/%s/%s", l.managementURL, url.PathEscape(l.vhost), url.PathEscape(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	l.lock.RLock()
+	req.SetBasicAuth(l.username, l.password)
+	l.lock.RUnlock()
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		var info queueInfo
+		return &info, json.NewDecoder(res.Body).Decode(&info)
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("management api responded with %s for queue %s", res.Status, name)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/streadway/amqp"
+)
+
+// PlanAction describes what the connector would do with an entity of the topology
+type PlanAction string
+
+// Possible outcomes of planning an entity
+const (
+	PlanCreate     PlanAction = "create"
+	PlanMatch      PlanAction = "match"
+	PlanUnverified PlanAction = "unverified"
+	PlanConflict   PlanAction = "conflict"
+	PlanEnsure     PlanAction = "ensure"
+)
+
+// unverifiedDetail explains why the arguments of existing entities are not compared
+const unverifiedDetail = "exists, %s can not be verified via AMQP without modifying the broker"
+
+// PlanEntry describes what would happen to a single exchange, queue or binding
+type PlanEntry struct {
+	Kind   string
+	Name   string
+	Action PlanAction
+	Detail string
+}
+
+// Plan inspects the broker and reports for every exchange, queue and binding of the topology whether it would be created
+// or already exists. Entities are only declared passively, so the broker is never modified. As passive declarations
+// ignore the arguments, the arguments of existing entities can not be verified and are reported as unverified.
+func Plan(creator ChannelCreator, t types.Topology) ([]PlanEntry, error) {
+	var entries []PlanEntry
+
+	for _, definition := range t {
+		ex := definition
+		ex.EnsureCorrectType()
+
+		entry, err := planExchange(creator, &ex)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+
+		for _, topic := range ex.Topics {
+			name := GenerateQueueName(ex.Name, topic)
+
+			entry, err := planQueue(creator, &ex, name)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+
+			// Bindings can not be inspected via AMQP, for existing queues they are ensured as binding is idempotent
+			binding := PlanEntry{Kind: "binding", Name: fmt.Sprintf("%s -> %s", ex.Name, name), Action: PlanEnsure, Detail: "routing key " + topic}
+			if entry.Action == PlanCreate {
+				binding.Action = PlanCreate
+			}
+			entries = append(entries, binding)
+		}
+	}
+
+	return entries, nil
+}
+
+func planExchange(creator ChannelCreator, ex *types.Exchange) (PlanEntry, error) {
+	entry := PlanEntry{Kind: "exchange", Name: ex.Name}
+	description := fmt.Sprintf("type=%s durable=%t auto-deleted=%t", ex.Type, ex.Durable, ex.AutoDeleted)
+
+	exists, err := inspect(creator, func(channel RabbitChannel) error {
+		return channel.ExchangeDeclarePassive(ex.Name, ex.Type, ex.Durable, ex.AutoDeleted, false, false, amqp.Table{})
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	switch {
+	case !exists && ex.Declare:
+		entry.Action = PlanCreate
+		entry.Detail = description
+	case !exists:
+		entry.Action = PlanConflict
+		entry.Detail = "exchange does not exist and is not declared by the connector"
+	case !ex.Declare:
+		entry.Action = PlanMatch
+		entry.Detail = "exists and is not declared by the connector"
+	default:
+		entry.Action = PlanUnverified
+		entry.Detail = fmt.Sprintf(unverifiedDetail, description)
+	}
+
+	return entry, nil
+}
+
+func planQueue(creator ChannelCreator, ex *types.Exchange, name string) (PlanEntry, error) {
+	entry := PlanEntry{Kind: "queue", Name: name}
+	description := fmt.Sprintf("durable=%t auto-deleted=%t", ex.Durable, ex.AutoDeleted)
+
+	exists, err := inspect(creator, func(channel RabbitChannel) error {
+		_, err := channel.QueueDeclarePassive(name, ex.Durable, ex.AutoDeleted, false, false, amqp.Table{})
+		return err
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	if !exists {
+		entry.Action = PlanCreate
+		entry.Detail = description
+		return entry, nil
+	}
+
+	entry.Action = PlanUnverified
+	entry.Detail = fmt.Sprintf(unverifiedDetail, description)
+	return entry, nil
+}
+
+// inspect runs a passive declaration on a fresh channel, as RabbitMQ closes the channel if the entity does not exist
+func inspect(creator ChannelCreator, declare func(channel RabbitChannel) error) (bool, error) {
+	channel, err := creator.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer channel.Close()
+
+	err = declare(channel)
+
+	var amqpErr *amqp.Error
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
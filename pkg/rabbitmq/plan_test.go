@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	notFound := &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange"}
+
+	t.Run("Should report entities that would be created", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("ExchangeDeclarePassive", "Dax", "direct", true, false, false, false, amqp.Table{}).Return(notFound)
+		channel.On("QueueDeclarePassive", "OpenFaaS_Dax_BMW", true, false, false, false, amqp.Table{}).Return(amqp.Queue{}, notFound)
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		entries, err := Plan(creator, types.Topology{{Name: "Dax", Topics: []string{"BMW"}, Declare: true, Type: "fanout", Durable: true}})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, []PlanEntry{
+			{Kind: "exchange", Name: "Dax", Action: PlanCreate, Detail: "type=direct durable=true auto-deleted=false"},
+			{Kind: "queue", Name: "OpenFaaS_Dax_BMW", Action: PlanCreate, Detail: "durable=true auto-deleted=false"},
+			{Kind: "binding", Name: "Dax -> OpenFaaS_Dax_BMW", Action: PlanCreate, Detail: "routing key BMW"},
+		}, entries)
+		channel.AssertExpectations(t)
+	})
+
+	t.Run("Should only declare existing entities passively and report their arguments as unverified", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("ExchangeDeclarePassive", "Dax", "topic", false, false, false, false, amqp.Table{}).Return(nil)
+		channel.On("QueueDeclarePassive", "OpenFaaS_Dax_BMW", false, false, false, false, amqp.Table{}).Return(amqp.Queue{}, nil)
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		entries, err := Plan(creator, types.Topology{{Name: "Dax", Topics: []string{"BMW"}, Declare: true, Type: "topic"}})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, []PlanEntry{
+			{Kind: "exchange", Name: "Dax", Action: PlanUnverified, Detail: "exists, type=topic durable=false auto-deleted=false can not be verified via AMQP without modifying the broker"},
+			{Kind: "queue", Name: "OpenFaaS_Dax_BMW", Action: PlanUnverified, Detail: "exists, durable=false auto-deleted=false can not be verified via AMQP without modifying the broker"},
+			{Kind: "binding", Name: "Dax -> OpenFaaS_Dax_BMW", Action: PlanEnsure, Detail: "routing key BMW"},
+		}, entries)
+		channel.AssertExpectations(t)
+		channel.AssertNotCalled(t, "ExchangeDeclare", "Dax", "topic", false, false, false, false, amqp.Table{})
+		channel.AssertNotCalled(t, "QueueDeclare", "OpenFaaS_Dax_BMW", false, false, false, false, amqp.Table{})
+	})
+
+	t.Run("Should report missing exchange that is not declared as conflict", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("ExchangeDeclarePassive", "Dax", "direct", false, false, false, false, amqp.Table{}).Return(notFound)
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		entries, err := Plan(creator, types.Topology{{Name: "Dax", Declare: false}})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, PlanConflict, entries[0].Action)
+		channel.AssertNotCalled(t, "ExchangeDeclare")
+	})
+
+	t.Run("Should return unexpected errors", func(t *testing.T) {
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(new(channelMock), errors.New("channel error"))
+
+		_, err := Plan(creator, types.Topology{{Name: "Dax"}})
+
+		assert.Error(t, err, "channel error")
+	})
+}
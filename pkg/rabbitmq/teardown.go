@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/streadway/amqp"
+)
+
+// TeardownOptions controls which entities are deleted and under which conditions
+type TeardownOptions struct {
+	// IfEmpty only deletes queues without messages
+	IfEmpty bool
+	// IfUnused only deletes queues without consumers and exchanges without bindings
+	IfUnused bool
+	// Exchanges also deletes exchanges that are declared by the connector
+	Exchanges bool
+	// DryRun only lists what would be deleted
+	DryRun bool
+}
+
+// TeardownStatus describes the outcome of deleting an entity
+type TeardownStatus string
+
+// Possible outcomes of deleting an entity
+const (
+	TeardownPlanned TeardownStatus = "planned"
+	TeardownDeleted TeardownStatus = "deleted"
+	TeardownSkipped TeardownStatus = "skipped"
+)
+
+// TeardownStep describes the deletion of a single queue or exchange
+type TeardownStep struct {
+	Kind   string
+	Name   string
+	Status TeardownStatus
+	Detail string
+}
+
+// Teardown deletes the queues the connector generated for the topology, which also removes their bindings. Optionally
+// exchanges declared by the connector are deleted afterwards. Entities that do not exist or are refused by RabbitMQ due
+// to the safety options are skipped, while other errors abort the teardown.
+func Teardown(creator ChannelCreator, t types.Topology, opts TeardownOptions) ([]TeardownStep, error) {
+	var steps []TeardownStep
+
+	for _, ex := range t {
+		for _, topic := range ex.Topics {
+			name := GenerateQueueName(ex.Name, topic)
+			step := TeardownStep{Kind: "queue", Name: name, Status: TeardownPlanned, Detail: fmt.Sprintf("including binding to %s with routing key %s", ex.Name, topic)}
+
+			if !opts.DryRun {
+				var purged int
+				err := teardownStep(creator, &step, func(channel RabbitChannel) (err error) {
+					purged, err = channel.QueueDelete(name, opts.IfUnused, opts.IfEmpty, false)
+					return err
+				})
+				if err != nil {
+					return steps, err
+				}
+
+				if step.Status == TeardownDeleted {
+					step.Detail = fmt.Sprintf("dropped %d message(s)", purged)
+				}
+			}
+
+			steps = append(steps, step)
+		}
+	}
+
+	if !opts.Exchanges {
+		return steps, nil
+	}
+
+	for _, ex := range t {
+		if !ex.Declare {
+			continue
+		}
+
+		step := TeardownStep{Kind: "exchange", Name: ex.Name, Status: TeardownPlanned}
+		if !opts.DryRun {
+			err := teardownStep(creator, &step, func(channel RabbitChannel) error {
+				return channel.ExchangeDelete(ex.Name, opts.IfUnused, false)
+			})
+			if err != nil {
+				return steps, err
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// teardownStep runs the deletion on a fresh channel, as RabbitMQ closes the channel if the deletion is refused
+func teardownStep(creator ChannelCreator, step *TeardownStep, remove func(channel RabbitChannel) error) error {
+	channel, err := creator.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	err = remove(channel)
+
+	var amqpErr *amqp.Error
+	switch {
+	case err == nil:
+		step.Status = TeardownDeleted
+		step.Detail = ""
+		return nil
+	case errors.As(err, &amqpErr) && (amqpErr.Code == amqp.NotFound || amqpErr.Code == amqp.PreconditionFailed):
+		step.Status = TeardownSkipped
+		step.Detail = amqpErr.Reason
+		return nil
+	default:
+		return err
+	}
+}
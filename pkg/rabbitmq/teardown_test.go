@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Templum/rabbitmq-connector/pkg/types"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeardown(t *testing.T) {
+	topology := types.Topology{
+		{Name: "Dax", Topics: []string{"BMW", "SAP"}, Declare: true},
+		{Name: "Nasdaq", Topics: []string{"Tesla"}, Declare: false},
+	}
+
+	t.Run("Should only list entities during dry run", func(t *testing.T) {
+		creator := new(creatorMock)
+
+		steps, err := Teardown(creator, topology, TeardownOptions{Exchanges: true, DryRun: true})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Len(t, steps, 4, "should list three queues and one exchange")
+		assert.Equal(t, TeardownStep{Kind: "exchange", Name: "Dax", Status: TeardownPlanned}, steps[3])
+		creator.AssertNotCalled(t, "Channel", nil)
+	})
+
+	t.Run("Should delete queues and skip refused ones", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("QueueDelete", "OpenFaaS_Dax_BMW", true, true, false).Return(0, nil)
+		channel.On("QueueDelete", "OpenFaaS_Dax_SAP", true, true, false).Return(0, &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - queue not empty"})
+		channel.On("QueueDelete", "OpenFaaS_Nasdaq_Tesla", true, true, false).Return(0, &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no queue"})
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		steps, err := Teardown(creator, topology, TeardownOptions{IfEmpty: true, IfUnused: true})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, []TeardownStep{
+			{Kind: "queue", Name: "OpenFaaS_Dax_BMW", Status: TeardownDeleted, Detail: "dropped 0 message(s)"},
+			{Kind: "queue", Name: "OpenFaaS_Dax_SAP", Status: TeardownSkipped, Detail: "PRECONDITION_FAILED - queue not empty"},
+			{Kind: "queue", Name: "OpenFaaS_Nasdaq_Tesla", Status: TeardownSkipped, Detail: "NOT_FOUND - no queue"},
+		}, steps)
+		channel.AssertNotCalled(t, "ExchangeDelete")
+	})
+
+	t.Run("Should delete declared exchanges if requested", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("QueueDelete", "OpenFaaS_Dax_BMW", false, false, false).Return(5, nil)
+		channel.On("ExchangeDelete", "Dax", false, false).Return(nil)
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		steps, err := Teardown(creator, types.Topology{{Name: "Dax", Topics: []string{"BMW"}, Declare: true}}, TeardownOptions{Exchanges: true})
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, []TeardownStep{
+			{Kind: "queue", Name: "OpenFaaS_Dax_BMW", Status: TeardownDeleted, Detail: "dropped 5 message(s)"},
+			{Kind: "exchange", Name: "Dax", Status: TeardownDeleted},
+		}, steps)
+		channel.AssertExpectations(t)
+	})
+
+	t.Run("Should abort on unexpected errors", func(t *testing.T) {
+		channel := new(channelMock)
+		channel.On("Close", nil).Return(nil)
+		channel.On("QueueDelete", "OpenFaaS_Dax_BMW", false, false, false).Return(0, errors.New("connection lost"))
+
+		creator := new(creatorMock)
+		creator.On("Channel", nil).Return(channel, nil)
+
+		steps, err := Teardown(creator, topology, TeardownOptions{})
+
+		assert.Error(t, err, "connection lost")
+		assert.Empty(t, steps, "should not report unfinished steps")
+	})
+}
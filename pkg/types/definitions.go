@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Definitions is the subset of a RabbitMQ management definitions export that describes the routing
+type Definitions struct {
+	Exchanges []DefinitionExchange `json:"exchanges"`
+	Queues    []DefinitionQueue    `json:"queues"`
+	Bindings  []DefinitionBinding  `json:"bindings"`
+}
+
+// DefinitionExchange describes an exchange within the definitions
+type DefinitionExchange struct {
+	Name       string                 `json:"name"`
+	VHost      string                 `json:"vhost"`
+	Type       string                 `json:"type"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Internal   bool                   `json:"internal"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+// DefinitionQueue describes a queue within the definitions
+type DefinitionQueue struct {
+	Name       string                 `json:"name"`
+	VHost      string                 `json:"vhost"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+// DefinitionBinding describes a binding within the definitions
+type DefinitionBinding struct {
+	Source          string                 `json:"source"`
+	VHost           string                 `json:"vhost"`
+	Destination     string                 `json:"destination"`
+	DestinationType string                 `json:"destination_type"`
+	RoutingKey      string                 `json:"routing_key"`
+	Arguments       map[string]interface{} `json:"arguments"`
+}
+
+// IsDefinitions reports whether the json document is a definitions export, which in contrast to a topology is an object
+func IsDefinitions(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// ReadTopologyFromDefinitions reads a RabbitMQ definitions export from the specified path and converts it into a Topology.
+func ReadTopologyFromDefinitions(fs afero.Fs, path string, vhost string, prefix string) (Topology, error) {
+	jsonFile, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	jsonFile, err = Interpolate(jsonFile)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	var defs Definitions
+	err = json.Unmarshal(jsonFile, &defs)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	return TopologyFromDefinitions(defs, vhost, prefix), nil
+}
+
+// TopologyFromDefinitions converts the exchanges of the vhost into a Topology, optionally only considering exchanges
+// starting with the prefix. The routing keys of the queue bindings of an exchange become its topics, so exchanges without
+// queue bindings are skipped, as well as exchanges of types other than direct and topic, whose deliveries do not carry the
+// bound routing key. The predefined amq.* exchanges, which are not part of an export, are consumed from without declaring them.
+// Bindings with wildcards are skipped unless the exchange is known to be a direct one, as deliveries are only accepted for a
+// topic that equals their routing key.
+func TopologyFromDefinitions(defs Definitions, vhost string, prefix string) Topology {
+	topics := make(map[string][]string)
+	var sources []string
+
+	direct := make(map[string]bool)
+	for _, ex := range defs.Exchanges {
+		if ex.VHost == vhost && strings.EqualFold(ex.Type, "direct") {
+			direct[ex.Name] = true
+		}
+	}
+
+	for _, binding := range defs.Bindings {
+		if binding.VHost != vhost || binding.DestinationType != "queue" || len(binding.Source) == 0 {
+			continue
+		}
+
+		if !direct[binding.Source] && hasWildcard(binding.RoutingKey) {
+			log.Printf("Binding of exchange %s with routing key %s contains a wildcard, will skip it", binding.Source, binding.RoutingKey)
+			continue
+		}
+
+		if _, known := topics[binding.Source]; !known {
+			sources = append(sources, binding.Source)
+		}
+
+		if !contains(topics[binding.Source], binding.RoutingKey) {
+			topics[binding.Source] = append(topics[binding.Source], binding.RoutingKey)
+		}
+	}
+
+	out := Topology{}
+	defined := make(map[string]bool)
+
+	for _, ex := range defs.Exchanges {
+		if ex.VHost != vhost || len(ex.Name) == 0 || !strings.HasPrefix(ex.Name, prefix) {
+			continue
+		}
+
+		if len(topics[ex.Name]) == 0 {
+			log.Printf("Exchange %s has no queue bindings in the definitions, will skip it", ex.Name)
+			continue
+		}
+
+		defined[ex.Name] = true
+
+		kind := strings.ToLower(ex.Type)
+		if kind != "direct" && kind != "topic" {
+			log.Printf("Exchange %s is of unsupported type %s, will skip it", ex.Name, ex.Type)
+			continue
+		}
+
+		out = append(out, Exchange{
+			Name:        ex.Name,
+			Topics:      topics[ex.Name],
+			Declare:     !strings.HasPrefix(ex.Name, "amq."),
+			Type:        kind,
+			Durable:     ex.Durable,
+			AutoDeleted: ex.AutoDelete,
+		})
+	}
+
+	for _, source := range sources {
+		if defined[source] || !strings.HasPrefix(source, prefix) {
+			continue
+		}
+
+		out = append(out, Exchange{
+			Name:    source,
+			Topics:  topics[source],
+			Declare: false,
+		})
+	}
+
+	return out
+}
+
+// hasWildcard reports whether the routing key of a topic binding contains a word matching any word
+func hasWildcard(routingKey string) bool {
+	for _, word := range strings.Split(routingKey, ".") {
+		if word == "*" || word == "#" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+
+	return false
+}
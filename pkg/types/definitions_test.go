@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+const exampleDefinitions = `{
+  "rabbit_version": "3.8.9",
+  "users": [{"name": "guest", "tags": "administrator"}],
+  "exchanges": [
+    {"name": "billing", "vhost": "/", "type": "topic", "durable": true, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "audit", "vhost": "/", "type": "fanout", "durable": false, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "unbound", "vhost": "/", "type": "direct", "durable": true, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "billing", "vhost": "staging", "type": "direct", "durable": false, "auto_delete": true, "internal": false, "arguments": {}}
+  ],
+  "queues": [
+    {"name": "invoices", "vhost": "/", "durable": true, "auto_delete": false, "arguments": {}}
+  ],
+  "bindings": [
+    {"source": "billing", "vhost": "/", "destination": "invoices", "destination_type": "queue", "routing_key": "invoice.created", "arguments": {}},
+    {"source": "billing", "vhost": "/", "destination": "ledger", "destination_type": "queue", "routing_key": "invoice.paid", "arguments": {}},
+    {"source": "billing", "vhost": "/", "destination": "archive", "destination_type": "queue", "routing_key": "invoice.created", "arguments": {}},
+    {"source": "billing", "vhost": "/", "destination": "audit", "destination_type": "exchange", "routing_key": "#", "arguments": {}},
+    {"source": "audit", "vhost": "/", "destination": "log", "destination_type": "queue", "routing_key": "", "arguments": {}},
+    {"source": "amq.topic", "vhost": "/", "destination": "log", "destination_type": "queue", "routing_key": "log.*", "arguments": {}},
+    {"source": "billing", "vhost": "staging", "destination": "invoices", "destination_type": "queue", "routing_key": "staging", "arguments": {}}
+  ]
+}`
+
+func TestTopologyFromDefinitions(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "definitions.json", []byte(exampleDefinitions), 0644)
+
+	t.Run("Should convert exchanges and bindings of the vhost", func(t *testing.T) {
+		topology, err := ReadTopologyFromDefinitions(fs, "definitions.json", "/", "")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, Topology{
+			{Name: "billing", Topics: []string{"invoice.created", "invoice.paid"}, Declare: true, Type: "topic", Durable: true},
+		}, topology, "Should skip amq.topic whose only binding has a wildcard")
+	})
+
+	t.Run("Should skip bindings with wildcards unless the exchange is direct", func(t *testing.T) {
+		topology := TopologyFromDefinitions(Definitions{
+			Exchanges: []DefinitionExchange{
+				{Name: "events", VHost: "/", Type: "topic"},
+				{Name: "commands", VHost: "/", Type: "direct"},
+			},
+			Bindings: []DefinitionBinding{
+				{Source: "events", VHost: "/", Destination: "all", DestinationType: "queue", RoutingKey: "#"},
+				{Source: "events", VHost: "/", Destination: "orders", DestinationType: "queue", RoutingKey: "order.*.created"},
+				{Source: "events", VHost: "/", Destination: "orders", DestinationType: "queue", RoutingKey: "order.created"},
+				{Source: "commands", VHost: "/", Destination: "all", DestinationType: "queue", RoutingKey: "#"},
+				{Source: "amq.topic", VHost: "/", Destination: "log", DestinationType: "queue", RoutingKey: "log.#"},
+			},
+		}, "/", "")
+
+		assert.Equal(t, Topology{
+			{Name: "events", Topics: []string{"order.created"}, Declare: true, Type: "topic"},
+			{Name: "commands", Topics: []string{"#"}, Declare: true, Type: "direct"},
+		}, topology)
+	})
+
+	t.Run("Should only consider exchanges with the prefix", func(t *testing.T) {
+		topology, err := ReadTopologyFromDefinitions(fs, "definitions.json", "staging", "bill")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, Topology{
+			{Name: "billing", Topics: []string{"staging"}, Declare: true, Type: "direct", AutoDeleted: true},
+		}, topology)
+	})
+
+	t.Run("Should return error for invalid definitions", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "invalid.json", []byte(`{"exchanges": {}}`), 0644)
+
+		_, err := ReadTopologyFromDefinitions(fs, "invalid.json", "/", "")
+
+		assert.Error(t, err, "should throw")
+	})
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActionKind names what happens with a delivery once its functions were invoked
+type ActionKind string
+
+// Supported kinds of actions
+const (
+	// ActionAck acknowledges the delivery, so it is removed from the queue
+	ActionAck ActionKind = "ack"
+	// ActionRequeue hands the delivery back to the queue right away
+	ActionRequeue ActionKind = "requeue"
+	// ActionReject rejects the delivery without requeueing it, so it is dead-lettered if the queue has a dead letter exchange
+	ActionReject ActionKind = "reject"
+	// ActionRetry holds the delivery for a delay before it is handed back to the queue
+	ActionRetry ActionKind = "retry-after"
+)
+
+// ActionHeader names the response header a function uses to request the action for the delivery it was invoked with
+const ActionHeader = "X-Rabbitmq-Action"
+
+// DefaultDisposition is the key of a disposition that applies to every failure without a more specific one
+const DefaultDisposition = "default"
+
+// Action describes what happens with a delivery, the delay is only used by ActionRetry
+type Action struct {
+	Kind  ActionKind
+	Delay time.Duration
+}
+
+// ParseAction parses an action like ack, requeue, reject or retry-after=30s
+func ParseAction(raw string) (Action, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+
+	switch ActionKind(value) {
+	case ActionAck, ActionRequeue, ActionReject:
+		return Action{Kind: ActionKind(value)}, nil
+	}
+
+	if delay, found := strings.CutPrefix(value, string(ActionRetry)+"="); found {
+		parsed, err := time.ParseDuration(delay)
+		if err != nil || parsed <= 0 {
+			return Action{}, fmt.Errorf("delay of action %s is not a positive duration, like 10s or 1m", raw)
+		}
+		return Action{Kind: ActionRetry, Delay: parsed}, nil
+	}
+
+	return Action{}, fmt.Errorf("action %s is not one of ack, requeue, reject or retry-after=<duration>", raw)
+}
+
+func (a Action) String() string {
+	if a.Kind == ActionRetry {
+		return fmt.Sprintf("%s=%s", a.Kind, a.Delay)
+	}
+	return string(a.Kind)
+}
+
+// defaultDispositions apply if a topic does not configure a disposition for the failure. Client errors will not
+// succeed on a retry, except for too many requests.
+var defaultDispositions = map[string]Action{
+	strconv.Itoa(429):        {Kind: ActionRequeue},
+	string(ClassClientError): {Kind: ActionReject},
+	DefaultDisposition:       {Kind: ActionRequeue},
+}
+
+// validDispositionKey reports whether the key is a status code, an ErrorClass or DefaultDisposition
+func validDispositionKey(key string) bool {
+	if code, err := strconv.Atoi(key); err == nil {
+		return code >= 100 && code <= 599
+	}
+
+	return key == DefaultDisposition || (ErrorClass(key) != ClassNone && ErrorClass(key).Known())
+}
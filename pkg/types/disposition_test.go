@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAction(t *testing.T) {
+	t.Run("Should parse actions in any case", func(t *testing.T) {
+		for raw, expected := range map[string]Action{
+			"ack":               {Kind: ActionAck},
+			"Requeue":           {Kind: ActionRequeue},
+			" REJECT ":          {Kind: ActionReject},
+			"retry-after=30s":   {Kind: ActionRetry, Delay: 30 * time.Second},
+			"Retry-After=1m30s": {Kind: ActionRetry, Delay: 90 * time.Second},
+		} {
+			action, err := ParseAction(raw)
+
+			assert.NoError(t, err, raw)
+			assert.Equal(t, expected, action, raw)
+		}
+	})
+
+	t.Run("Should reject invalid actions", func(t *testing.T) {
+		_, err := ParseAction("nack")
+		assert.EqualError(t, err, "action nack is not one of ack, requeue, reject or retry-after=<duration>")
+
+		_, err = ParseAction("retry-after=soon")
+		assert.EqualError(t, err, "delay of action retry-after=soon is not a positive duration, like 10s or 1m")
+
+		_, err = ParseAction("retry-after=0s")
+		assert.Error(t, err)
+	})
+
+	t.Run("Should print actions in their parsable form", func(t *testing.T) {
+		assert.Equal(t, "reject", Action{Kind: ActionReject}.String())
+		assert.Equal(t, "retry-after=30s", Action{Kind: ActionRetry, Delay: 30 * time.Second}.String())
+	})
+}
@@ -6,15 +6,28 @@
 package types
 
 import (
+	"log"
+	"strconv"
+	"time"
+
 	"github.com/streadway/amqp"
 )
 
+// DeadlineHeader names the header of a message carrying the point in time by which it has to be processed, either
+// as RFC 3339 timestamp or as milliseconds since the epoch
+const DeadlineHeader = "x-deadline"
+
 // OpenFaaSInvocation represent an Event Specification used during invocation
 type OpenFaaSInvocation struct {
 	ContentType     string
 	ContentEncoding string
 	Topic           string
 	Message         *[]byte
+
+	// Timeout configured for the topic, 0 if the timeout of the function or the connector applies
+	Timeout time.Duration
+	// Deadline derived from the message, zero if it has none
+	Deadline time.Time
 }
 
 // NewInvocation creates a OpenFaaSInvocation from an amqp.Delivery.
@@ -24,5 +37,61 @@ func NewInvocation(delivery amqp.Delivery) *OpenFaaSInvocation {
 		ContentEncoding: delivery.ContentEncoding,
 		Topic:           delivery.RoutingKey,
 		Message:         &delivery.Body,
+		Deadline:        deadlineOf(delivery, time.Now()),
+	}
+}
+
+// Expired reports whether the deadline of the message has passed already
+func (i *OpenFaaSInvocation) Expired() bool {
+	return !i.Deadline.IsZero() && !time.Now().Before(i.Deadline)
+}
+
+// deadlineOf derives the deadline from the deadline header or the expiration of the message, whichever is earlier.
+// The expiration counts from the timestamp of the message if it has one, otherwise from receiving it.
+func deadlineOf(delivery amqp.Delivery, received time.Time) time.Time {
+	var deadline time.Time
+
+	if raw, exists := delivery.Headers[DeadlineHeader]; exists {
+		deadline = parseDeadline(raw)
 	}
+
+	if len(delivery.Expiration) > 0 {
+		ttl, err := strconv.ParseInt(delivery.Expiration, 10, 64)
+		if err != nil || ttl < 0 {
+			log.Printf("Received delivery %d with invalid expiration %s, will ignore it", delivery.DeliveryTag, delivery.Expiration)
+			return deadline
+		}
+
+		start := received
+		if !delivery.Timestamp.IsZero() {
+			start = delivery.Timestamp
+		}
+
+		if expiry := start.Add(time.Duration(ttl) * time.Millisecond); deadline.IsZero() || expiry.Before(deadline) {
+			deadline = expiry
+		}
+	}
+
+	return deadline
+}
+
+func parseDeadline(raw interface{}) time.Time {
+	switch value := raw.(type) {
+	case time.Time:
+		return value
+	case int64:
+		return time.UnixMilli(value)
+	case int32:
+		return time.UnixMilli(int64(value))
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return parsed
+		}
+		if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.UnixMilli(millis)
+		}
+	}
+
+	log.Printf("Received invalid %s %v, will ignore it", DeadlineHeader, raw)
+	return time.Time{}
 }
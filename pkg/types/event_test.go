@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlineOf(t *testing.T) {
+	received := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Should have no deadline by default", func(t *testing.T) {
+		assert.True(t, deadlineOf(amqp.Delivery{}, received).IsZero())
+	})
+
+	t.Run("Should derive deadline from expiration", func(t *testing.T) {
+		deadline := deadlineOf(amqp.Delivery{Expiration: "30000"}, received)
+		assert.Equal(t, received.Add(30*time.Second), deadline)
+
+		published := received.Add(-10 * time.Second)
+		deadline = deadlineOf(amqp.Delivery{Expiration: "30000", Timestamp: published}, received)
+		assert.Equal(t, published.Add(30*time.Second), deadline, "Should count from timestamp")
+	})
+
+	t.Run("Should derive deadline from header", func(t *testing.T) {
+		expected := received.Add(time.Minute)
+
+		assert.True(t, expected.Equal(deadlineOf(amqp.Delivery{Headers: amqp.Table{DeadlineHeader: expected.Format(time.RFC3339)}}, received)))
+		assert.True(t, expected.Equal(deadlineOf(amqp.Delivery{Headers: amqp.Table{DeadlineHeader: expected.UnixMilli()}}, received)))
+		assert.True(t, expected.Equal(deadlineOf(amqp.Delivery{Headers: amqp.Table{DeadlineHeader: expected}}, received)))
+	})
+
+	t.Run("Should prefer the earlier deadline", func(t *testing.T) {
+		header := received.Add(10 * time.Second)
+
+		deadline := deadlineOf(amqp.Delivery{Expiration: "30000", Headers: amqp.Table{DeadlineHeader: header.Format(time.RFC3339)}}, received)
+		assert.True(t, header.Equal(deadline))
+	})
+
+	t.Run("Should ignore invalid values", func(t *testing.T) {
+		assert.True(t, deadlineOf(amqp.Delivery{Expiration: "soon", Headers: amqp.Table{DeadlineHeader: "tomorrow"}}, received).IsZero())
+	})
+}
+
+func TestOpenFaaSInvocation_Expired(t *testing.T) {
+	assert.False(t, (&OpenFaaSInvocation{}).Expired(), "Should not expire without deadline")
+	assert.False(t, (&OpenFaaSInvocation{Deadline: time.Now().Add(time.Minute)}).Expired())
+	assert.True(t, (&OpenFaaSInvocation{Deadline: time.Now().Add(-time.Second)}).Expired())
+}
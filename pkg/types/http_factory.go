@@ -13,8 +13,9 @@ import (
 	"github.com/valyala/fasthttp/fasthttpproxy"
 )
 
-// MakeHTTPClient generates an HTTP Client setting basic properties including timeouts
-func MakeHTTPClient(insecure bool, maxConnections int, timeout time.Duration) *fasthttp.Client {
+// MakeHTTPClient generates an HTTP Client setting basic properties including timeouts. The TLS config is used for https
+// connections, e.g. to trust the CA of the gateway or to present a client certificate.
+func MakeHTTPClient(tlsConfig *tls.Config, maxConnections int, timeout time.Duration) *fasthttp.Client {
 	client := fasthttp.Client{
 		Name: "Main_Client",
 
@@ -26,8 +27,7 @@ func MakeHTTPClient(insecure bool, maxConnections int, timeout time.Duration) *f
 		WriteTimeout: timeout,
 
 		MaxIdleConnDuration: 5 * time.Second,
-		/* #nosec G402 as default is false*/
-		TLSConfig: &tls.Config{InsecureSkipVerify: insecure},
+		TLSConfig:           tlsConfig,
 
 		MaxConnsPerHost: maxConnections,
 	}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	t.Run("Should trust the CA of the TLS config", func(t *testing.T) {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+
+		client := MakeHTTPClient(&tls.Config{RootCAs: roots}, 1, time.Second)
+		status, _, err := client.Get(nil, server.URL)
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("Should reject unknown CA", func(t *testing.T) {
+		client := MakeHTTPClient(&tls.Config{}, 1, time.Second)
+		_, _, err := client.Get(nil, server.URL)
+
+		assert.Error(t, err, "should throw")
+	})
+}
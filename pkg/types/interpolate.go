@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Interpolate replaces ${VAR} and ${VAR:-default} placeholders with the value of the environment variable.
+// The default is used if the variable is unset or empty, while an unset variable without a default is an error.
+func Interpolate(data []byte) ([]byte, error) {
+	var missing []string
+
+	out := placeholder.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := placeholder.FindSubmatch(match)
+		name, hasDefault := string(groups[1]), len(groups[2]) > 0
+
+		if value, ok := os.LookupEnv(name); ok && (len(value) > 0 || !hasDefault) {
+			return []byte(value)
+		}
+
+		if hasDefault {
+			return groups[3]
+		}
+
+		missing = append(missing, name)
+		return match
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variable(s) %s referenced without default are not set", strings.Join(missing, ", "))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("TEST_EXCHANGE", "Nasdaq")
+	os.Setenv("TEST_EMPTY", "")
+	defer os.Unsetenv("TEST_EXCHANGE")
+	defer os.Unsetenv("TEST_EMPTY")
+
+	t.Run("Should replace set variables", func(t *testing.T) {
+		actual, err := Interpolate([]byte("name: ${TEST_EXCHANGE}-${TEST_EXCHANGE:-Other}"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "name: Nasdaq-Nasdaq", string(actual))
+	})
+
+	t.Run("Should use default for unset or empty variables", func(t *testing.T) {
+		actual, err := Interpolate([]byte("name: ${TEST_UNSET:-Dev} ${TEST_EMPTY:-false} ${TEST_EMPTY}"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "name: Dev false ", string(actual))
+	})
+
+	t.Run("Should fail for unset variables without default", func(t *testing.T) {
+		_, err := Interpolate([]byte("name: ${TEST_UNSET} ${TEST_OTHER_UNSET}"))
+
+		assert.EqualError(t, err, "environment variable(s) TEST_UNSET, TEST_OTHER_UNSET referenced without default are not set")
+	})
+}
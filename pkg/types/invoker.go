@@ -5,8 +5,201 @@
 
 package types
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrUnauthorized is returned if OpenFaaS refused the credentials of the connector
+var ErrUnauthorized = errors.New("OpenFaaS Credentials are invalid")
+
+// Errors matching the classes of failed invocations, an InvocationError can be checked against them with errors.Is
+var (
+	ErrNotFound    = errors.New("function is not deployed")
+	ErrClientError = errors.New("function refused the request")
+	ErrServerError = errors.New("function failed")
+	ErrTimeout     = errors.New("function did not respond in time")
+	ErrTransport   = errors.New("function could not be reached")
+)
+
 // Invoker is the Interface used by the OpenFaaS Connector SDK to perform invocations
-// of Lambdas based on a provided topic and message
+// of Lambdas based on a provided topic and message. The context limits the lifetime of the
+// invocation, cancelling it aborts the outstanding calls.
 type Invoker interface {
-	Invoke(topic string, invocation *OpenFaaSInvocation) error
+	Invoke(ctx context.Context, topic string, invocation *OpenFaaSInvocation) (InvocationResult, error)
+}
+
+// ErrDraining is returned if an invocation stopped waiting in between attempts, as the consumer of the delivery drains
+var ErrDraining = fmt.Errorf("consumer is draining: %w", context.Canceled)
+
+type drainKey struct{}
+
+// WithDrain returns a context carrying a channel that is closed once the consumer of the delivery drains. Invokers stop
+// waiting in between attempts once it is closed, while outstanding calls are allowed to finish.
+func WithDrain(ctx context.Context, drained <-chan struct{}) context.Context {
+	return context.WithValue(ctx, drainKey{}, drained)
+}
+
+// Drained returns the channel passed to WithDrain, which is nil and therefore never ready if there is none
+func Drained(ctx context.Context) <-chan struct{} {
+	drained, _ := ctx.Value(drainKey{}).(<-chan struct{})
+	return drained
+}
+
+// ErrorClass describes why invoking a function failed
+type ErrorClass string
+
+// Supported classes of errors, ClassNone is used for successful invocations
+const (
+	ClassNone         ErrorClass = ""
+	ClassUnauthorized ErrorClass = "unauthorized"
+	ClassNotFound     ErrorClass = "not-found"
+	ClassClientError  ErrorClass = "client-error"
+	ClassServerError  ErrorClass = "server-error"
+	ClassTimeout      ErrorClass = "timeout"
+	ClassCanceled     ErrorClass = "canceled"
+	ClassTransport    ErrorClass = "transport"
+)
+
+var classErrors = map[ErrorClass]error{
+	ClassUnauthorized: ErrUnauthorized,
+	ClassNotFound:     ErrNotFound,
+	ClassClientError:  ErrClientError,
+	ClassServerError:  ErrServerError,
+	ClassTimeout:      ErrTimeout,
+	ClassCanceled:     context.Canceled,
+	ClassTransport:    ErrTransport,
+}
+
+// Known reports whether the class is one of the supported ones
+func (c ErrorClass) Known() bool {
+	_, known := classErrors[c]
+	return known || c == ClassNone
+}
+
+// InvocationError is returned if invoking a function failed, it carries the status code of the response, which
+// is 0 if there was none, and the class of the failure
+type InvocationError struct {
+	Function   string
+	StatusCode int
+	Class      ErrorClass
+	Err        error
+}
+
+// NewInvocationError wraps the error of invoking the function and classifies it
+func NewInvocationError(function string, statusCode int, err error) *InvocationError {
+	return &InvocationError{Function: function, StatusCode: statusCode, Class: Classify(statusCode, err), Err: err}
+}
+
+func (e *InvocationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *InvocationError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error of the class, e.g. ErrServerError for a 503
+func (e *InvocationError) Is(target error) bool {
+	return classErrors[e.Class] == target
+}
+
+// Classify derives the class of an error from the status code of the response, falling back to the error itself
+// if there was no response
+func Classify(statusCode int, err error) ErrorClass {
+	var invocationErr *InvocationError
+
+	switch {
+	case statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices:
+		return ClassNone
+	case err == nil && statusCode < http.StatusBadRequest:
+		return ClassNone
+	case statusCode == 0 && errors.As(err, &invocationErr):
+		return invocationErr.Class
+	case statusCode == http.StatusUnauthorized || errors.Is(err, ErrUnauthorized):
+		return ClassUnauthorized
+	case statusCode == http.StatusNotFound:
+		return ClassNotFound
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ClassTimeout
+	case statusCode >= http.StatusInternalServerError:
+		return ClassServerError
+	case statusCode >= http.StatusBadRequest:
+		return ClassClientError
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, fasthttp.ErrTimeout):
+		return ClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	default:
+		return ClassTransport
+	}
+}
+
+// FunctionResult is the outcome of invoking a single function, which is the last attempt if it was retried.
+// The status code is 0 if no response was received.
+type FunctionResult struct {
+	Function   string
+	StatusCode int
+	Body       []byte
+	Header     http.Header
+	Duration   time.Duration
+	Attempts   int
+	Class      ErrorClass
+	Err        error
+}
+
+// InvocationResult contains the outcome of every function invoked for a topic, in the order they were invoked.
+// Functions after the first failing one are not invoked.
+type InvocationResult struct {
+	Topic     string
+	Functions []FunctionResult
+}
+
+// Failed returns the result of the function that failed, nil if all of them succeeded
+func (r InvocationResult) Failed() *FunctionResult {
+	for i := range r.Functions {
+		if r.Functions[i].Err != nil {
+			return &r.Functions[i]
+		}
+	}
+
+	return nil
+}
+
+// RequestedAction returns the action a function requested via the ActionHeader. If a function failed its request
+// decides, otherwise the request of the first function that sent one. Invalid requests are ignored.
+func (r InvocationResult) RequestedAction() (Action, bool) {
+	if failed := r.Failed(); failed != nil {
+		return failed.requestedAction()
+	}
+
+	for i := range r.Functions {
+		if action, requested := r.Functions[i].requestedAction(); requested {
+			return action, true
+		}
+	}
+
+	return Action{}, false
+}
+
+func (f *FunctionResult) requestedAction() (Action, bool) {
+	raw := f.Header.Get(ActionHeader)
+	if len(raw) == 0 {
+		return Action{}, false
+	}
+
+	action, err := ParseAction(raw)
+	if err != nil {
+		log.Printf("Function %s requested an invalid %s, will ignore it: %s", f.Function, ActionHeader, err)
+		return Action{}, false
+	}
+
+	return action, true
 }
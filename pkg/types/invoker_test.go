@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestClassify(t *testing.T) {
+	t.Run("Should classify successful invocations as none", func(t *testing.T) {
+		assert.Equal(t, ClassNone, Classify(200, nil))
+		assert.Equal(t, ClassNone, Classify(202, nil))
+		assert.Equal(t, ClassNone, Classify(204, nil))
+	})
+
+	t.Run("Should classify every successful status code as none", func(t *testing.T) {
+		failed := errors.New("failed")
+
+		assert.Equal(t, ClassNone, Classify(201, failed))
+		assert.Equal(t, ClassNone, Classify(204, failed))
+		assert.Equal(t, ClassNone, Classify(299, failed))
+	})
+
+	t.Run("Should classify by status code", func(t *testing.T) {
+		failed := errors.New("failed")
+
+		assert.Equal(t, ClassUnauthorized, Classify(401, failed))
+		assert.Equal(t, ClassNotFound, Classify(404, failed))
+		assert.Equal(t, ClassTimeout, Classify(408, failed))
+		assert.Equal(t, ClassTimeout, Classify(504, failed))
+		assert.Equal(t, ClassServerError, Classify(500, failed))
+		assert.Equal(t, ClassServerError, Classify(503, failed))
+		assert.Equal(t, ClassClientError, Classify(400, failed))
+		assert.Equal(t, ClassClientError, Classify(422, failed))
+	})
+
+	t.Run("Should classify by error without response", func(t *testing.T) {
+		assert.Equal(t, ClassUnauthorized, Classify(0, ErrUnauthorized))
+		assert.Equal(t, ClassTimeout, Classify(0, context.DeadlineExceeded))
+		assert.Equal(t, ClassCanceled, Classify(0, context.Canceled))
+		assert.Equal(t, ClassTimeout, Classify(0, fasthttp.ErrTimeout))
+		assert.Equal(t, ClassTransport, Classify(0, errors.New("connection refused")))
+	})
+
+	t.Run("Should keep class of invocation errors", func(t *testing.T) {
+		assert.Equal(t, ClassServerError, Classify(0, fmt.Errorf("wrapped: %w", NewInvocationError("biller", 503, errors.New("failed")))))
+	})
+}
+
+func TestInvocationError(t *testing.T) {
+	t.Run("Should match the error of its class", func(t *testing.T) {
+		err := NewInvocationError("biller", 404, errors.New("Function biller is not deployed"))
+
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NotErrorIs(t, err, ErrServerError)
+		assert.Equal(t, "Function biller is not deployed", err.Error())
+	})
+
+	t.Run("Should unwrap the underlying error", func(t *testing.T) {
+		err := NewInvocationError("biller", 0, fmt.Errorf("unable to invoke function biller: %w", context.DeadlineExceeded))
+
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, ClassTimeout, err.Class)
+	})
+}
+
+func TestInvocationResult_Failed(t *testing.T) {
+	t.Run("Should return nil if all functions succeeded", func(t *testing.T) {
+		result := InvocationResult{Topic: "Billing", Functions: []FunctionResult{{Function: "biller"}, {Function: "auditor"}}}
+		assert.Nil(t, result.Failed())
+	})
+
+	t.Run("Should return the failed function", func(t *testing.T) {
+		result := InvocationResult{Topic: "Billing", Functions: []FunctionResult{{Function: "biller"}, {Function: "auditor", Err: errors.New("failed")}}}
+
+		failed := result.Failed()
+		assert.NotNil(t, failed)
+		assert.Equal(t, "auditor", failed.Function)
+	})
+}
+
+func TestInvocationResult_RequestedAction(t *testing.T) {
+	requesting := func(function string, action string) FunctionResult {
+		return FunctionResult{Function: function, Header: http.Header{ActionHeader: []string{action}}}
+	}
+
+	t.Run("Should report no action if none was requested", func(t *testing.T) {
+		_, requested := InvocationResult{Functions: []FunctionResult{{Function: "biller"}}}.RequestedAction()
+		assert.False(t, requested)
+	})
+
+	t.Run("Should return action of the first requesting function", func(t *testing.T) {
+		result := InvocationResult{Functions: []FunctionResult{{Function: "biller"}, requesting("auditor", "reject"), requesting("logger", "ack")}}
+
+		action, requested := result.RequestedAction()
+		assert.True(t, requested)
+		assert.Equal(t, Action{Kind: ActionReject}, action)
+	})
+
+	t.Run("Should return action of the failed function", func(t *testing.T) {
+		failed := requesting("auditor", "retry-after=30s")
+		failed.Err = errors.New("failed")
+		result := InvocationResult{Functions: []FunctionResult{requesting("biller", "ack"), failed}}
+
+		action, requested := result.RequestedAction()
+		assert.True(t, requested)
+		assert.Equal(t, Action{Kind: ActionRetry, Delay: 30 * time.Second}, action)
+	})
+
+	t.Run("Should ignore invalid actions", func(t *testing.T) {
+		_, requested := InvocationResult{Functions: []FunctionResult{requesting("biller", "drop")}}.RequestedAction()
+		assert.False(t, requested)
+	})
+}
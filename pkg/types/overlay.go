@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+// Overlay patches the exchanges of a base topology, e.g. per environment
+type Overlay []ExchangeOverlay
+
+// ExchangeOverlay patches the exchange with the same name. Only the fields that are set are applied,
+// where topics, timeouts and dispositions replace the ones of the base. Exchanges that are not part of the base are added.
+type ExchangeOverlay struct {
+	Name        string    `json:"name" yaml:"name"`
+	Topics      *[]string `json:"topics,omitempty" yaml:"topics,omitempty"`
+	Declare     *bool     `json:"declare,omitempty" yaml:"declare,omitempty"`
+	Type        *string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Durable     *bool     `json:"durable,omitempty" yaml:"durable,omitempty"`
+	AutoDeleted *bool     `json:"auto-deleted,omitempty" yaml:"auto-deleted,omitempty"`
+	Remove      bool      `json:"remove,omitempty" yaml:"remove,omitempty"`
+
+	Timeouts     *map[string]string            `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+	Dispositions *map[string]map[string]string `json:"dispositions,omitempty" yaml:"dispositions,omitempty"`
+}
+
+// ReadOverlayFromFile reads an overlay file in yaml format from the specified path, after interpolating environment variables.
+func ReadOverlayFromFile(fs afero.Fs, path string) (Overlay, error) {
+	yamlFile, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	yamlFile, err = Interpolate(yamlFile)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	var out Overlay
+	err = yaml.UnmarshalStrict(yamlFile, &out)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	return out, nil
+}
+
+// Apply returns a copy of the topology with the overlay applied, the topology itself is not modified.
+func (o Overlay) Apply(t Topology) (Topology, error) {
+	out := make(Topology, 0, len(t))
+	for _, ex := range t {
+		ex.Topics = append([]string{}, ex.Topics...)
+		out = append(out, ex)
+	}
+
+	for _, patch := range o {
+		index := -1
+		for i := range out {
+			if out[i].Name == patch.Name {
+				index = i
+				break
+			}
+		}
+
+		switch {
+		case len(patch.Name) == 0:
+			return Topology{}, fmt.Errorf("overlay contains an exchange without name")
+		case patch.Remove && index < 0:
+			return Topology{}, fmt.Errorf("overlay removes exchange %s, which is not part of the topology", patch.Name)
+		case patch.Remove:
+			out = append(out[:index], out[index+1:]...)
+			continue
+		case index < 0 && patch.Topics == nil:
+			return Topology{}, fmt.Errorf("overlay adds exchange %s without topics", patch.Name)
+		case index < 0:
+			out = append(out, Exchange{Name: patch.Name})
+			index = len(out) - 1
+		}
+
+		patch.applyTo(&out[index])
+	}
+
+	return out, nil
+}
+
+func (p *ExchangeOverlay) applyTo(ex *Exchange) {
+	if p.Topics != nil {
+		ex.Topics = append([]string{}, *p.Topics...)
+	}
+	if p.Declare != nil {
+		ex.Declare = *p.Declare
+	}
+	if p.Type != nil {
+		ex.Type = *p.Type
+	}
+	if p.Durable != nil {
+		ex.Durable = *p.Durable
+	}
+	if p.AutoDeleted != nil {
+		ex.AutoDeleted = *p.AutoDeleted
+	}
+	if p.Timeouts != nil {
+		ex.Timeouts = *p.Timeouts
+	}
+	if p.Dispositions != nil {
+		ex.Dispositions = *p.Dispositions
+	}
+}
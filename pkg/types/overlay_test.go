@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOverlayFromFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	t.Run("Should read overlay", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "prod.yaml", []byte(`- name: AEx
+  durable: true`), 0644)
+
+		actual, err := ReadOverlayFromFile(fs, "prod.yaml")
+
+		assert.NoError(t, err)
+		assert.Len(t, actual, 1)
+		assert.True(t, *actual[0].Durable)
+		assert.Nil(t, actual[0].Topics, "Should not set topics")
+	})
+
+	t.Run("Should reject unknown fields", func(t *testing.T) {
+		_ = afero.WriteFile(fs, "typo.yaml", []byte(`- name: AEx
+  durabel: true`), 0644)
+
+		_, err := ReadOverlayFromFile(fs, "typo.yaml")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestOverlay_Apply(t *testing.T) {
+	durable := true
+	topics := []string{"Baz"}
+	base := Topology{
+		{Name: "AEx", Topics: []string{"Foo"}, Declare: true, Type: "direct"},
+		{Name: "BEx", Topics: []string{"Bar"}, Declare: true},
+	}
+
+	t.Run("Should only patch set fields", func(t *testing.T) {
+		actual, err := Overlay{{Name: "AEx", Durable: &durable}}.Apply(base)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Exchange{Name: "AEx", Topics: []string{"Foo"}, Declare: true, Type: "direct", Durable: true}, actual[0])
+		assert.False(t, base[0].Durable, "Should not modify base")
+	})
+
+	t.Run("Should replace timeouts", func(t *testing.T) {
+		timeouts := map[string]string{"Foo": "1m"}
+		actual, err := Overlay{{Name: "AEx", Timeouts: &timeouts}}.Apply(base)
+
+		assert.NoError(t, err)
+		assert.Equal(t, timeouts, actual[0].Timeouts)
+		assert.Nil(t, base[0].Timeouts, "Should not modify base")
+	})
+
+	t.Run("Should replace dispositions", func(t *testing.T) {
+		dispositions := map[string]map[string]string{"Foo": {"client-error": "ack"}}
+		actual, err := Overlay{{Name: "AEx", Dispositions: &dispositions}}.Apply(base)
+
+		assert.NoError(t, err)
+		assert.Equal(t, dispositions, actual[0].Dispositions)
+		assert.Nil(t, base[0].Dispositions, "Should not modify base")
+	})
+
+	t.Run("Should add and remove exchanges", func(t *testing.T) {
+		actual, err := Overlay{{Name: "BEx", Remove: true}, {Name: "CEx", Topics: &topics}}.Apply(base)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Topology{base[0], {Name: "CEx", Topics: []string{"Baz"}}}, actual)
+		assert.Len(t, base, 2, "Should not modify base")
+	})
+
+	t.Run("Should fail for added exchange without topics", func(t *testing.T) {
+		_, err := Overlay{{Name: "CEx", Durable: &durable}}.Apply(base)
+
+		assert.EqualError(t, err, "overlay adds exchange CEx without topics")
+	})
+
+	t.Run("Should fail for removing unknown exchange", func(t *testing.T) {
+		_, err := Overlay{{Name: "CEx", Remove: true}}.Apply(base)
+
+		assert.EqualError(t, err, "overlay removes exchange CEx, which is not part of the topology")
+	})
+}
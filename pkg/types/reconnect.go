@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy describes how often and how fast (re)connecting to RabbitMQ is attempted
+type ReconnectPolicy struct {
+	// MaxAttempts limits the attempts, 0 means retrying infinitely
+	MaxAttempts int
+	// BaseDelay is the delay after the first failed attempt, which doubles with every further attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+	// Jitter is the fraction between 0 and 1 by which the delay is randomly shortened
+	Jitter float64
+}
+
+// Exhausted reports whether no further attempt should be made after the provided number of attempts
+func (p ReconnectPolicy) Exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
+
+// Delay returns how long to wait after the provided number of failed attempts, which starts with 1
+func (p ReconnectPolicy) Delay(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		/* #nosec G404 jitter does not need a secure random source */
+		delay -= time.Duration(float64(delay) * p.Jitter * rand.Float64())
+	}
+
+	return delay
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectPolicy_Delay(t *testing.T) {
+	t.Run("Should double delay until max delay", func(t *testing.T) {
+		policy := ReconnectPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+		assert.Equal(t, time.Second, policy.Delay(1))
+		assert.Equal(t, 2*time.Second, policy.Delay(2))
+		assert.Equal(t, 4*time.Second, policy.Delay(3))
+		assert.Equal(t, 5*time.Second, policy.Delay(4))
+		assert.Equal(t, 5*time.Second, policy.Delay(100))
+	})
+
+	t.Run("Should shorten delay by at most the jitter", func(t *testing.T) {
+		policy := ReconnectPolicy{BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 0.5}
+
+		for i := 0; i < 100; i++ {
+			delay := policy.Delay(1)
+			assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			assert.LessOrEqual(t, delay, time.Second)
+		}
+	})
+}
+
+func TestReconnectPolicy_Exhausted(t *testing.T) {
+	assert.False(t, ReconnectPolicy{}.Exhausted(1000), "should retry infinitely without max attempts")
+	assert.False(t, ReconnectPolicy{MaxAttempts: 3}.Exhausted(2))
+	assert.True(t, ReconnectPolicy{MaxAttempts: 3}.Exhausted(3))
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"time"
+)
+
+// RetryPolicy describes how often and how fast a failed function is invoked again, before the delivery is handed
+// back to RabbitMQ
+type RetryPolicy struct {
+	// MaxAttempts limits the attempts per function, 1 disables retrying
+	MaxAttempts int
+	// BaseDelay is the delay after the first failed attempt, which doubles with every further attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts
+	MaxDelay time.Duration
+	// Jitter is the fraction between 0 and 1 by which the delay is randomly shortened
+	Jitter float64
+	// Budget limits the time spent on a function including its retries, 0 means it is only limited by MaxAttempts
+	Budget time.Duration
+	// Classes of failures that are retried
+	Classes []ErrorClass
+}
+
+// Retryable reports whether a failure of the class is retried
+func (p RetryPolicy) Retryable(class ErrorClass) bool {
+	for _, retryable := range p.Classes {
+		if retryable == class {
+			return class != ClassNone
+		}
+	}
+
+	return false
+}
+
+// Exhausted reports whether no further attempt should be made after the provided number of attempts
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return attempts >= p.MaxAttempts
+}
+
+// Delay returns how long to wait after the provided number of failed attempts, which starts with 1
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	return ReconnectPolicy{BaseDelay: p.BaseDelay, MaxDelay: p.MaxDelay, Jitter: p.Jitter}.Delay(attempts)
+}
+
+// Fits reports whether an attempt after the delay would still start within the budget, for a function first invoked at start
+func (p RetryPolicy) Fits(start time.Time, delay time.Duration) bool {
+	return p.Budget <= 0 || time.Since(start)+delay <= p.Budget
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond, Budget: time.Second,
+		Classes: []ErrorClass{ClassTransport, ClassServerError}}
+
+	t.Run("Should only retry the configured classes", func(t *testing.T) {
+		assert.True(t, policy.Retryable(ClassTransport))
+		assert.True(t, policy.Retryable(ClassServerError))
+		assert.False(t, policy.Retryable(ClassClientError))
+		assert.False(t, policy.Retryable(ClassNone))
+	})
+
+	t.Run("Should be exhausted after max attempts", func(t *testing.T) {
+		assert.False(t, policy.Exhausted(2))
+		assert.True(t, policy.Exhausted(3))
+		assert.True(t, RetryPolicy{MaxAttempts: 1}.Exhausted(1), "Should not retry with a single attempt")
+	})
+
+	t.Run("Should double delay until max delay", func(t *testing.T) {
+		assert.Equal(t, 100*time.Millisecond, policy.Delay(1))
+		assert.Equal(t, 200*time.Millisecond, policy.Delay(2))
+		assert.Equal(t, 250*time.Millisecond, policy.Delay(3))
+	})
+
+	t.Run("Should only fit attempts within the budget", func(t *testing.T) {
+		start := time.Now().Add(-800 * time.Millisecond)
+
+		assert.True(t, policy.Fits(start, 100*time.Millisecond))
+		assert.False(t, policy.Fits(start, 250*time.Millisecond))
+		assert.True(t, RetryPolicy{}.Fits(start, time.Hour), "Should not limit without budget")
+	})
+}
@@ -6,30 +6,82 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v2"
 )
 
 // Topology definition
-type Topology []struct {
-	Name        string   `json:"name"`
-	Topics      []string `json:"topics"`
-	Declare     bool     `json:"declare"`
-	Type        string   `json:"type,omitempty"`
-	Durable     bool     `json:"durable,omitempty"`
-	AutoDeleted bool     `json:"auto-deleted,omitempty"`
-}
+type Topology []Exchange
 
 // Exchange Definition of a RabbitMQ Exchange
 type Exchange struct {
-	Name        string
-	Topics      []string
-	Declare     bool
-	Type        string
-	Durable     bool
-	AutoDeleted bool
+	Name        string   `json:"name" yaml:"name"`
+	Topics      []string `json:"topics" yaml:"topics"`
+	Declare     bool     `json:"declare" yaml:"declare"`
+	Type        string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Durable     bool     `json:"durable,omitempty" yaml:"durable,omitempty"`
+	AutoDeleted bool     `json:"auto-deleted,omitempty" yaml:"auto-deleted,omitempty"`
+
+	Timeouts     map[string]string            `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+	Dispositions map[string]map[string]string `json:"dispositions,omitempty" yaml:"dispositions,omitempty"`
+}
+
+// Timeout returns the invocation timeout configured for the topic, which is 0 if there is none. Timeouts that are not
+// a valid positive Duration are ignored, strict topologies reject them upfront.
+func (e *Exchange) Timeout(topic string) time.Duration {
+	raw, exists := e.Timeouts[topic]
+	if !exists {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 {
+		log.Printf("Provided timeout %s for topic %s of exchange %s is not a valid Duration, like 10s or 1m. Will ignore it", raw, topic, e.Name)
+		return 0
+	}
+
+	return timeout
+}
+
+// Disposition returns the action for a delivery of the topic whose invocation ended with the status code and class.
+// Successful invocations are acknowledged. For failures the status code, the class and DefaultDisposition of the topic
+// are looked up in this order, only if the topic configures none of them the defaults are looked up the same way.
+// Invalid actions are ignored, strict topologies reject them upfront.
+func (e *Exchange) Disposition(topic string, statusCode int, class ErrorClass) Action {
+	if class == ClassNone {
+		return Action{Kind: ActionAck}
+	}
+
+	keys := []string{string(class), DefaultDisposition}
+	if statusCode > 0 {
+		keys = append([]string{strconv.Itoa(statusCode)}, keys...)
+	}
+
+	configured := e.Dispositions[topic]
+	for _, key := range keys {
+		if raw, exists := configured[key]; exists {
+			action, err := ParseAction(raw)
+			if err == nil {
+				return action
+			}
+			log.Printf("Provided disposition %s for topic %s of exchange %s is invalid: %s. Will ignore it", key, topic, e.Name, err)
+		}
+	}
+
+	for _, key := range keys {
+		if action, exists := defaultDispositions[key]; exists {
+			return action
+		}
+	}
+
+	return defaultDispositions[DefaultDisposition]
 }
 
 // EnsureCorrectType is responsible to make sure that the read-in type is one of the allowed
@@ -45,7 +97,7 @@ func (e *Exchange) EnsureCorrectType() {
 	}
 }
 
-// ReadTopologyFromFile reads a topology file in yaml format from the specified path.
+// ReadTopologyFromFile reads a topology file in yaml or json format from the specified path and interpolates environment variables.
 // Further it parses the file and returns it already in the Topology struct format.
 func ReadTopologyFromFile(fs afero.Fs, path string) (Topology, error) {
 	yamlFile, err := afero.ReadFile(fs, path)
@@ -53,6 +105,41 @@ func ReadTopologyFromFile(fs afero.Fs, path string) (Topology, error) {
 		return Topology{}, err
 	}
 
+	yamlFile, err = Interpolate(yamlFile)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	var out Topology
+	err = yaml.Unmarshal(yamlFile, &out)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	return out, nil
+}
+
+// ReadTopologyFromFileStrict reads a topology file like ReadTopologyFromFile, but rejects it if ValidateTopology
+// reports any problem. The returned error contains all of the problems.
+func ReadTopologyFromFileStrict(fs afero.Fs, path string) (Topology, error) {
+	yamlFile, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	yamlFile, err = Interpolate(yamlFile)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	if problems := ValidateTopology(yamlFile); len(problems) > 0 {
+		errs := make([]error, len(problems))
+		for i, problem := range problems {
+			errs[i] = problem
+		}
+		return Topology{}, fmt.Errorf("topology %s is invalid: %w", path, errors.Join(errs...))
+	}
+
 	var out Topology
 	err = yaml.Unmarshal(yamlFile, &out)
 	if err != nil {
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchange_Timeout(t *testing.T) {
+	ex := Exchange{Name: "AEx", Topics: []string{"Foo", "Bar", "Baz"}, Timeouts: map[string]string{"Foo": "1m", "Bar": "soon"}}
+
+	t.Run("Should return timeout of topic", func(t *testing.T) {
+		assert.Equal(t, time.Minute, ex.Timeout("Foo"))
+	})
+
+	t.Run("Should ignore missing or invalid timeouts", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), ex.Timeout("Bar"))
+		assert.Equal(t, time.Duration(0), ex.Timeout("Baz"))
+	})
+}
+
+func TestExchange_Disposition(t *testing.T) {
+	ex := Exchange{Name: "AEx", Topics: []string{"Foo", "Bar"}, Dispositions: map[string]map[string]string{
+		"Foo": {"503": "retry-after=30s", "server-error": "reject", "default": "ack", "timeout": "later"},
+	}}
+
+	t.Run("Should acknowledge successful invocations", func(t *testing.T) {
+		assert.Equal(t, Action{Kind: ActionAck}, ex.Disposition("Foo", 200, ClassNone))
+	})
+
+	t.Run("Should prefer status code over error class", func(t *testing.T) {
+		assert.Equal(t, Action{Kind: ActionRetry, Delay: 30 * time.Second}, ex.Disposition("Foo", 503, ClassServerError))
+		assert.Equal(t, Action{Kind: ActionReject}, ex.Disposition("Foo", 500, ClassServerError))
+	})
+
+	t.Run("Should fall back to default of topic", func(t *testing.T) {
+		assert.Equal(t, Action{Kind: ActionAck}, ex.Disposition("Foo", 0, ClassTransport))
+		assert.Equal(t, Action{Kind: ActionAck}, ex.Disposition("Foo", 0, ClassTimeout), "Should ignore invalid actions")
+	})
+
+	t.Run("Should prefer default of topic over built-in defaults", func(t *testing.T) {
+		requeue := Exchange{Name: "AEx", Topics: []string{"Foo"}, Dispositions: map[string]map[string]string{
+			"Foo": {"default": "requeue"},
+		}}
+
+		assert.Equal(t, Action{Kind: ActionRequeue}, requeue.Disposition("Foo", 400, ClassClientError))
+		assert.Equal(t, Action{Kind: ActionRequeue}, requeue.Disposition("Foo", 422, ClassClientError))
+	})
+
+	t.Run("Should prefer error class of topic over built-in defaults for the status code", func(t *testing.T) {
+		ack := Exchange{Name: "AEx", Topics: []string{"Foo"}, Dispositions: map[string]map[string]string{
+			"Foo": {"client-error": "ack"},
+		}}
+
+		assert.Equal(t, Action{Kind: ActionAck}, ack.Disposition("Foo", 429, ClassClientError))
+	})
+
+	t.Run("Should fall back to defaults", func(t *testing.T) {
+		assert.Equal(t, Action{Kind: ActionReject}, ex.Disposition("Bar", 400, ClassClientError))
+		assert.Equal(t, Action{Kind: ActionRequeue}, ex.Disposition("Bar", 429, ClassClientError))
+		assert.Equal(t, Action{Kind: ActionRequeue}, ex.Disposition("Bar", 502, ClassServerError))
+		assert.Equal(t, Action{Kind: ActionRequeue}, ex.Disposition("Bar", 0, ClassTransport))
+	})
+}
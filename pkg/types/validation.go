@@ -0,0 +1,309 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TopologyError describes a problem found during validation of a topology
+type TopologyError struct {
+	Line    int
+	Message string
+}
+
+func (e TopologyError) Error() string {
+	if e.Line <= 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+var syntaxErrLine = regexp.MustCompile(`line (\d+): `)
+
+// TopologySchema is the JSON Schema describing a topology, it defines the fields ValidateTopology accepts
+//
+//go:embed topology.schema.json
+var TopologySchema []byte
+
+// schemaNode is the subset of JSON Schema the TopologySchema uses. Problems are phrased by the errorMessage of the
+// violated keyword, where {subject} names the validated value and {value} is its quoted content.
+type schemaNode struct {
+	Title         string                 `json:"title"`
+	Type          string                 `json:"type"`
+	Pattern       string                 `json:"pattern"`
+	MinItems      int                    `json:"minItems"`
+	UniqueItems   bool                   `json:"uniqueItems"`
+	Required      []string               `json:"required"`
+	Items         *schemaNode            `json:"items"`
+	Properties    map[string]*schemaNode `json:"properties"`
+	PropertyNames *schemaNode            `json:"propertyNames"`
+	Additional    json.RawMessage        `json:"additionalProperties"`
+	Ref           string                 `json:"$ref"`
+	Defs          map[string]*schemaNode `json:"$defs"`
+	ErrorMessage  map[string]string      `json:"errorMessage"`
+
+	pattern    *regexp.Regexp
+	closed     bool
+	additional *schemaNode
+}
+
+var topologySchema = loadTopologySchema()
+
+var exchangeSchema = topologySchema.Items
+
+func loadTopologySchema() *schemaNode {
+	var schema schemaNode
+	if err := json.Unmarshal(TopologySchema, &schema); err != nil {
+		panic(fmt.Sprintf("embedded topology schema is invalid: %s", err))
+	}
+
+	return schema.resolve(schema.Defs)
+}
+
+// resolve replaces references with their definition, compiles patterns and parses additionalProperties
+func (s *schemaNode) resolve(defs map[string]*schemaNode) *schemaNode {
+	if s == nil {
+		return nil
+	}
+
+	if len(s.Ref) > 0 {
+		def, exists := defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !exists {
+			panic(fmt.Sprintf("embedded topology schema references unknown definition %s", s.Ref))
+		}
+		return def.resolve(defs)
+	}
+
+	if len(s.Pattern) > 0 {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+
+	s.Items = s.Items.resolve(defs)
+	s.PropertyNames = s.PropertyNames.resolve(defs)
+	for name, property := range s.Properties {
+		s.Properties[name] = property.resolve(defs)
+	}
+
+	switch raw := strings.TrimSpace(string(s.Additional)); raw {
+	case "", "true":
+	case "false":
+		s.closed = true
+	default:
+		var additional schemaNode
+		if err := json.Unmarshal(s.Additional, &additional); err != nil {
+			panic(fmt.Sprintf("embedded topology schema is invalid: %s", err))
+		}
+		s.additional = additional.resolve(defs)
+	}
+
+	return s
+}
+
+func (s *schemaNode) problem(keyword string, line int, subject string, value string) TopologyError {
+	message, exists := s.ErrorMessage[keyword]
+	if !exists {
+		message = "{subject} violates " + keyword
+	}
+
+	return TopologyError{Line: line, Message: strings.NewReplacer("{subject}", subject, "{value}", strconv.Quote(value)).Replace(message)}
+}
+
+// ValidateTopology strictly validates the provided topology, either in yaml or json, against the TopologySchema and
+// returns every problem it found. In contrast to ReadTopologyFromFile it rejects unknown fields, unknown exchange types,
+// missing topics and exchanges that are defined more than once. The latter is the only check not driven by the schema,
+// as JSON Schema cannot express unique fields of objects.
+func ValidateTopology(data []byte) []TopologyError {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []TopologyError{syntaxError(err)}
+	}
+
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return []TopologyError{topologySchema.problem("minItems", 1, "topology", "")}
+	}
+
+	root := doc.Content[0]
+	problems := validateNode(root, topologySchema, "topology", "")
+
+	if root.Kind == yaml.SequenceNode {
+		seen := make(map[string]int)
+
+		for _, entry := range root.Content {
+			name := exchangeName(entry)
+			if len(name) == 0 {
+				continue
+			}
+
+			if line, exists := seen[name]; exists {
+				problems = append(problems, TopologyError{Line: entry.Line, Message: fmt.Sprintf("exchange %q is already defined in line %d", name, line)})
+			} else {
+				seen[name] = entry.Line
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems
+}
+
+func exchangeName(entry *yaml.Node) string {
+	if entry.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if key, value := entry.Content[i], entry.Content[i+1]; key.Value == "name" && value.Kind == yaml.ScalarNode {
+			return strings.TrimSpace(value.Value)
+		}
+	}
+
+	return ""
+}
+
+// validateNode validates the value against the schema. The subject names the value in problems, while the context names
+// the map entry it belongs to, like ` of topic "Foo"`.
+func validateNode(value *yaml.Node, schema *schemaNode, subject string, context string) []TopologyError {
+	if !matchesType(value, schema.Type) {
+		return []TopologyError{schema.problem("type", value.Line, subject, value.Value)}
+	}
+
+	switch schema.Type {
+	case "array":
+		return validateList(value, schema, subject, context)
+	case "object":
+		return validateMap(value, schema, context)
+	}
+
+	if schema.pattern != nil && !schema.pattern.MatchString(value.Value) {
+		return []TopologyError{schema.problem("pattern", value.Line, subject, value.Value)}
+	}
+
+	return nil
+}
+
+func validateList(value *yaml.Node, schema *schemaNode, subject string, context string) []TopologyError {
+	if len(value.Content) < schema.MinItems {
+		return []TopologyError{schema.problem("minItems", value.Line, subject, value.Value)}
+	}
+
+	var problems []TopologyError
+	seen := make(map[string]bool)
+
+	for _, item := range value.Content {
+		if schema.Items != nil {
+			itemProblems := validateNode(item, schema.Items, schema.Items.Title, context)
+			problems = append(problems, itemProblems...)
+
+			if len(itemProblems) > 0 {
+				continue
+			}
+		}
+
+		if schema.UniqueItems && item.Kind == yaml.ScalarNode {
+			if seen[item.Value] {
+				problems = append(problems, TopologyError{Line: item.Line, Message: fmt.Sprintf("%s %q is listed more than once", schema.Items.Title, item.Value)})
+			}
+			seen[item.Value] = true
+		}
+	}
+
+	return problems
+}
+
+func validateMap(value *yaml.Node, schema *schemaNode, context string) []TopologyError {
+	keyTitle := "field"
+	if schema.PropertyNames != nil && len(schema.PropertyNames.Title) > 0 {
+		keyTitle = schema.PropertyNames.Title
+	}
+
+	var problems []TopologyError
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, child := value.Content[i], value.Content[i+1]
+
+		if seen[key.Value] {
+			problems = append(problems, TopologyError{Line: key.Line, Message: fmt.Sprintf("%s %q is defined more than once", keyTitle, key.Value)})
+			continue
+		}
+		seen[key.Value] = true
+
+		if property, known := schema.Properties[key.Value]; known {
+			problems = append(problems, validateNode(child, property, fmt.Sprintf("field %q", key.Value), context)...)
+			continue
+		}
+
+		if schema.closed {
+			problems = append(problems, TopologyError{Line: key.Line, Message: fmt.Sprintf("unknown field %q", key.Value)})
+			continue
+		}
+
+		if schema.additional == nil {
+			continue
+		}
+
+		subject := fmt.Sprintf("%s %q%s", schema.additional.Title, key.Value, context)
+		entryContext := context
+		if len(context) == 0 {
+			subject = fmt.Sprintf("%s of %s %q", schema.additional.Title, keyTitle, key.Value)
+			entryContext = fmt.Sprintf(" of %s %q", keyTitle, key.Value)
+		}
+
+		if names := schema.PropertyNames; names != nil && names.pattern != nil && !names.pattern.MatchString(key.Value) {
+			problems = append(problems, names.problem("pattern", key.Line, subject, key.Value))
+		}
+
+		problems = append(problems, validateNode(child, schema.additional, subject, entryContext)...)
+	}
+
+	for _, required := range schema.Required {
+		if !seen[required] {
+			problems = append(problems, TopologyError{Line: value.Line, Message: fmt.Sprintf("missing required field %q", required)})
+		}
+	}
+
+	return problems
+}
+
+// matchesType reports whether the node has the JSON type, scalars like numbers are accepted as strings like the parser does
+func matchesType(value *yaml.Node, kind string) bool {
+	switch kind {
+	case "array":
+		return value.Kind == yaml.SequenceNode
+	case "object":
+		return value.Kind == yaml.MappingNode
+	case "boolean":
+		return value.Kind == yaml.ScalarNode && value.Tag == "!!bool"
+	case "string":
+		return value.Kind == yaml.ScalarNode
+	default:
+		return true
+	}
+}
+
+// syntaxError extracts the line from errors reported by the yaml parser
+func syntaxError(err error) TopologyError {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+
+	if match := syntaxErrLine.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return TopologyError{Line: line, Message: strings.Replace(message, match[0], "", 1)}
+	}
+
+	return TopologyError{Message: message}
+}
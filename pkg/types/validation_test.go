@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) Simon Pelczer 2021. All rights reserved.
+ *  Licensed under the MIT license. See LICENSE file in the project root for full license information.
+ */
+
+package types
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTopology(t *testing.T) {
+	t.Run("Should accept valid topology", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`- name: AEx
+  topics: [Foo, Bar]
+  declare: true
+  type: "Direct"
+  durable: false
+  auto-deleted: false
+- name: BEx
+  topics: [Dead, Beef]
+  timeouts:
+    Dead: 1m30s`))
+
+		assert.Empty(t, problems, "should not report problems")
+	})
+
+	t.Run("Should report every problem with its line", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`- name: AEx
+  topics: []
+  type: fanout
+  autodelete: true
+- name: AEx
+  topics: [Foo, Foo]
+  declare: "yes"
+- declare: true`))
+
+		assert.Equal(t, []TopologyError{
+			{Line: 2, Message: `field "topics" has to contain at least one topic`},
+			{Line: 3, Message: `unknown exchange type "fanout", has to be either direct or topic`},
+			{Line: 4, Message: `unknown field "autodelete"`},
+			{Line: 5, Message: `exchange "AEx" is already defined in line 1`},
+			{Line: 6, Message: `topic "Foo" is listed more than once`},
+			{Line: 7, Message: `field "declare" has to be either true or false`},
+			{Line: 8, Message: `missing required field "name"`},
+			{Line: 8, Message: `missing required field "topics"`},
+		}, problems)
+	})
+
+	t.Run("Should report invalid timeouts", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`- name: AEx
+  topics: [Foo, Bar]
+  timeouts:
+    Foo: soon
+    Bar: 0s
+- name: BEx
+  topics: [Foo]
+  timeouts: [10s]`))
+
+		assert.Equal(t, []TopologyError{
+			{Line: 4, Message: `timeout of topic "Foo" has to be a positive duration, like 10s or 1m`},
+			{Line: 5, Message: `timeout of topic "Bar" has to be a positive duration, like 10s or 1m`},
+			{Line: 8, Message: `field "timeouts" has to be a map of topics to timeouts`},
+		}, problems)
+	})
+
+	t.Run("Should report invalid dispositions", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`- name: AEx
+  topics: [Foo, Bar]
+  dispositions:
+    Foo:
+      "429": retry-after=30s
+      client-error: reject
+      default: Requeue
+      "999": ack
+      bad-request: ack
+      server-error: retry-after=soon
+    Bar: reject
+- name: BEx
+  topics: [Foo]
+  dispositions: [reject]`))
+
+		assert.Equal(t, []TopologyError{
+			{Line: 8, Message: `disposition "999" of topic "Foo" is neither a status code, an error class nor default`},
+			{Line: 9, Message: `disposition "bad-request" of topic "Foo" is neither a status code, an error class nor default`},
+			{Line: 10, Message: `disposition "server-error" of topic "Foo" has to be one of ack, requeue, reject or retry-after=<duration>`},
+			{Line: 11, Message: `dispositions of topic "Bar" have to be a map of status codes or error classes to actions`},
+			{Line: 14, Message: `field "dispositions" has to be a map of topics to dispositions`},
+		}, problems)
+	})
+
+	t.Run("Should report syntax errors with their line", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`- name: AEx
+  topics: [Foo]
+	declare: true`))
+
+		assert.Equal(t, []TopologyError{{Line: 3, Message: "found character that cannot start any token"}}, problems)
+	})
+
+	t.Run("Should validate json topology", func(t *testing.T) {
+		problems := ValidateTopology([]byte(`[
+	{"name": "AEx", "topics": ["Foo"], "declare": true, "type": "topic"},
+	{"name": "BEx", "topics": ["Bar"], "auto_delete": true}
+]`))
+
+		assert.Equal(t, []TopologyError{{Line: 3, Message: `unknown field "auto_delete"`}}, problems)
+	})
+
+	t.Run("Should reject empty topology or wrong structure", func(t *testing.T) {
+		assert.Equal(t, "topology does not define any exchange", ValidateTopology([]byte(``))[0].Message)
+		assert.Equal(t, "topology has to be a list of exchanges", ValidateTopology([]byte(`name: AEx`))[0].Message)
+	})
+}
+
+func TestTopologySchema(t *testing.T) {
+	t.Run("Should describe every field of an exchange", func(t *testing.T) {
+		fields := reflect.TypeOf(Exchange{})
+		assert.Len(t, exchangeSchema.Properties, fields.NumField())
+
+		for i := 0; i < fields.NumField(); i++ {
+			name := strings.Split(fields.Field(i).Tag.Get("yaml"), ",")[0]
+			assert.Contains(t, exchangeSchema.Properties, name)
+		}
+	})
+
+	t.Run("Should require name and topics", func(t *testing.T) {
+		assert.Equal(t, []string{"name", "topics"}, exchangeSchema.Required)
+	})
+
+	t.Run("Should phrase every constraint", func(t *testing.T) {
+		var check func(path string, node *schemaNode)
+		check = func(path string, node *schemaNode) {
+			if node == nil {
+				return
+			}
+
+			if len(node.Type) > 0 {
+				assert.Contains(t, node.ErrorMessage, "type", "Expected message for type of %s", path)
+			}
+			if node.pattern != nil {
+				assert.Contains(t, node.ErrorMessage, "pattern", "Expected message for pattern of %s", path)
+			}
+			if node.MinItems > 0 {
+				assert.Contains(t, node.ErrorMessage, "minItems", "Expected message for minItems of %s", path)
+			}
+
+			check(path+"/items", node.Items)
+			check(path+"/propertyNames", node.PropertyNames)
+			check(path+"/additionalProperties", node.additional)
+			for name, property := range node.Properties {
+				check(path+"/"+name, property)
+			}
+		}
+
+		check("#", topologySchema)
+	})
+}
+
+func TestTopologySchema_AgreesWithParsers(t *testing.T) {
+	timeouts := exchangeSchema.Properties["timeouts"].additional
+	dispositions := exchangeSchema.Properties["dispositions"].additional
+
+	t.Run("Should accept the same exchange types", func(t *testing.T) {
+		for _, value := range []string{"direct", "Direct", "TOPIC", "topic", "fanout", "headers", "", "direct "} {
+			exchange := Exchange{Type: value}
+			exchange.EnsureCorrectType()
+
+			assert.Equal(t, strings.EqualFold(exchange.Type, value), exchangeSchema.Properties["type"].pattern.MatchString(value), "Disagree on type %q", value)
+		}
+	})
+
+	t.Run("Should accept the same timeouts", func(t *testing.T) {
+		for _, value := range []string{"10s", "1m30s", "1.5h", "0.5s", "100ms", "250us", "1h0m0s", "0s", "0.0s", "0m0s", "-1s", "soon", "10", ""} {
+			parsed, err := time.ParseDuration(value)
+
+			assert.Equal(t, err == nil && parsed > 0, timeouts.pattern.MatchString(value), "Disagree on timeout %q", value)
+		}
+	})
+
+	t.Run("Should accept the same disposition keys", func(t *testing.T) {
+		keys := []string{DefaultDisposition, "bad-request", "", "Default", "4xx"}
+		for code := 0; code < 1000; code++ {
+			keys = append(keys, strconv.Itoa(code))
+		}
+		for class := range classErrors {
+			keys = append(keys, string(class))
+		}
+
+		for _, key := range keys {
+			assert.Equal(t, validDispositionKey(key), dispositions.PropertyNames.pattern.MatchString(key), "Disagree on disposition key %q", key)
+		}
+	})
+
+	t.Run("Should accept the same actions", func(t *testing.T) {
+		for _, value := range []string{"ack", "Requeue", "REJECT", "retry-after=30s", "Retry-After=1m30s", "retry-after=0.5s",
+			"retry-after=0s", "retry-after=", "retry-after=soon", "retry-after", "drop", ""} {
+			_, err := ParseAction(value)
+
+			assert.Equal(t, err == nil, dispositions.additional.pattern.MatchString(value), "Disagree on action %q", value)
+		}
+	})
+}
+
+func TestReadTopologyFromFileStrict(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "valid.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  auto-deleted: true`), 0644)
+	_ = afero.WriteFile(fs, "invalid.yaml", []byte(`- name: AEx
+  topics: [Foo]
+  autodeleted: true
+  type: fanout`), 0644)
+
+	t.Run("Should read valid topology", func(t *testing.T) {
+		topology, err := ReadTopologyFromFileStrict(fs, "valid.yaml")
+
+		assert.NoError(t, err, "should not throw")
+		assert.Equal(t, Topology{{Name: "AEx", Topics: []string{"Foo"}, AutoDeleted: true}}, topology)
+	})
+
+	t.Run("Should return all problems of invalid topology", func(t *testing.T) {
+		_, err := ReadTopologyFromFileStrict(fs, "invalid.yaml")
+
+		assert.Error(t, err, "should throw")
+		assert.Contains(t, err.Error(), `line 3: unknown field "autodeleted"`)
+		assert.Contains(t, err.Error(), `line 4: unknown exchange type "fanout"`)
+	})
+}